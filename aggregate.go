@@ -0,0 +1,180 @@
+package boltutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Aggregation specifies how DB.Aggregate summarizes the records.
+type Aggregation struct {
+	fields  []string
+	groupBy func(k []byte, obj Storable) (string, bool)
+	decode  bool
+}
+
+// NewAggregation returns an Aggregation which calculates count, sum, min, max and avg of the numeric fields.
+// Nested fields can be accessed with dots, like "Owner.Age".
+func NewAggregation(fields ...string) *Aggregation {
+	return &Aggregation{
+		fields: fields,
+		decode: len(fields) > 0,
+	}
+}
+
+// GroupByField groups the records by the value of the field, which is formatted with fmt.Sprint.
+// Records without the field are not aggregated.
+func (a *Aggregation) GroupByField(field string) *Aggregation {
+	a.groupBy = func(k []byte, obj Storable) (string, bool) {
+		v, ok := fieldByName(obj, field)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprint(v.Interface()), true
+	}
+	a.decode = true
+	return a
+}
+
+// GroupByKeyPrefix groups the records by the part of key before the first sep,
+// the whole key is the group if it does not contain sep.
+func (a *Aggregation) GroupByKeyPrefix(sep []byte) *Aggregation {
+	a.groupBy = func(k []byte, obj Storable) (string, bool) {
+		if i := bytes.Index(k, sep); i >= 0 {
+			return string(k[:i]), true
+		}
+		return string(k), true
+	}
+	return a
+}
+
+// GroupByFunc groups the records by the result of fn, records are not aggregated if fn returns false.
+func (a *Aggregation) GroupByFunc(fn func(k []byte, obj Storable) (group string, ok bool)) *Aggregation {
+	a.groupBy = fn
+	a.decode = true
+	return a
+}
+
+func (a *Aggregation) getFields() []string {
+	if a == nil {
+		return nil
+	}
+	return a.fields
+}
+
+func (a *Aggregation) group(k []byte, obj Storable) (string, bool) {
+	if a == nil || a.groupBy == nil {
+		return "", true
+	}
+	return a.groupBy(k, obj)
+}
+
+func (a *Aggregation) needDecode() bool {
+	if a == nil {
+		return false
+	}
+	return a.decode
+}
+
+// Aggregate is the result of a group.
+type Aggregate struct {
+	Count  int                        // count of records in the group
+	Fields map[string]*FieldAggregate // results of the fields
+}
+
+// FieldAggregate is the result of a numeric field.
+type FieldAggregate struct {
+	Count int // count of records which have the field
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+// Avg returns the average value of the field.
+func (a *FieldAggregate) Avg() float64 {
+	if a.Count == 0 {
+		return 0
+	}
+	return a.Sum / float64(a.Count)
+}
+
+func (a *FieldAggregate) add(v float64) {
+	if a.Count == 0 || v < a.Min {
+		a.Min = v
+	}
+	if a.Count == 0 || v > a.Max {
+		a.Max = v
+	}
+	a.Count++
+	a.Sum += v
+}
+
+// Aggregate summarizes the records in the bucket which match the filter in a single read transaction.
+// The result is keyed by group, and there is only one group "" if the aggregation has no group by.
+func (d *DB) Aggregate(obj Storable, filter *Filter, aggregation *Aggregation) (map[string]*Aggregate, error) {
+	return d.AggregateContext(context.Background(), obj, filter, aggregation)
+}
+
+// AggregateContext is like Aggregate but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) AggregateContext(ctx context.Context, obj Storable, filter *Filter, aggregation *Aggregation) (_ map[string]*Aggregate, err error) {
+	op := d.newOperation(ctx, "aggregate", obj.BoltBucket(), nil)
+	defer op.finish(&err)
+
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	ret := map[string]*Aggregate{}
+
+	bucket := tx.Bucket(obj.BoltBucket())
+	if bucket == nil {
+		return ret, nil
+	}
+
+	itemType := reflect.TypeOf(obj).Elem()
+	if err := d.iterate(op, bucket.Cursor(), filter, func() Storable {
+		return reflect.New(itemType).Interface().(Storable)
+	}, aggregation.needDecode(), func(k, v []byte, obj Storable) (bool, error) {
+		group, ok := aggregation.group(k, obj)
+		if !ok {
+			return false, nil
+		}
+		result := ret[group]
+		if result == nil {
+			result = &Aggregate{
+				Fields: map[string]*FieldAggregate{},
+			}
+			ret[group] = result
+		}
+		result.Count++
+
+		for _, field := range aggregation.getFields() {
+			fieldValue, ok := fieldByName(obj, field)
+			if !ok {
+				continue
+			}
+			number, ok := toFloat(fieldValue)
+			if !ok {
+				return false, &OpError{
+					Bucket: obj.BoltBucket(),
+					Key:    bytes.Clone(k),
+					Err:    fmt.Errorf("field %q of %T is not numeric: %v", field, obj, fieldValue.Type()),
+				}
+			}
+			fieldResult := result.Fields[field]
+			if fieldResult == nil {
+				fieldResult = &FieldAggregate{}
+				result.Fields[field] = fieldResult
+			}
+			fieldResult.add(number)
+		}
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
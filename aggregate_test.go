@@ -0,0 +1,69 @@
+package boltutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Aggregate(t *testing.T) {
+	db := testDB(t, true)
+	defer db.Close()
+	require.NoError(t, db.MPut(
+		&Person{Id: "user/alice", Name: "Alice", Age: 17},
+		&Person{Id: "user/bob", Name: "Bob", Age: 18},
+		&Person{Id: "user/carol", Name: "Carol", Age: 30},
+		&Person{Id: "admin/dave", Name: "Dave", Age: 18},
+	))
+
+	t.Run("no group", func(t *testing.T) {
+		got, err := db.Aggregate(&Person{}, nil, NewAggregation("Age"))
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, 4, got[""].Count)
+		age := got[""].Fields["Age"]
+		assert.Equal(t, &FieldAggregate{Count: 4, Sum: 83, Min: 17, Max: 30}, age)
+		assert.Equal(t, 20.75, age.Avg())
+	})
+
+	t.Run("group by key prefix", func(t *testing.T) {
+		got, err := db.Aggregate(&Person{}, nil, NewAggregation().GroupByKeyPrefix([]byte("/")))
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, 3, got["user"].Count)
+		assert.Equal(t, 1, got["admin"].Count)
+	})
+
+	t.Run("group by field with filter", func(t *testing.T) {
+		got, err := db.Aggregate(&Person{}, NewFilter().SetPrefix([]byte("user/")), NewAggregation("Age").GroupByField("Age"))
+		require.NoError(t, err)
+		require.Len(t, got, 3)
+		assert.Equal(t, 1, got["18"].Count)
+		assert.Equal(t, 18.0, got["18"].Fields["Age"].Sum)
+	})
+
+	t.Run("group by func", func(t *testing.T) {
+		got, err := db.Aggregate(&Person{}, nil, NewAggregation("Age").GroupByFunc(func(k []byte, obj Storable) (string, bool) {
+			if obj.(*Person).Age < 18 {
+				return "", false
+			}
+			return "adult", true
+		}))
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, 3, got["adult"].Count)
+		assert.Equal(t, 22.0, got["adult"].Fields["Age"].Avg())
+	})
+
+	t.Run("not numeric", func(t *testing.T) {
+		_, err := db.Aggregate(&Person{}, nil, NewAggregation("Name"))
+		assert.Error(t, err)
+	})
+
+	t.Run("bucket not exists", func(t *testing.T) {
+		got, err := db.Aggregate(&Wind{}, nil, nil)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+}
@@ -2,13 +2,19 @@ package boltutil
 
 import (
 	"bytes"
+	"errors"
 )
 
 type Filter struct {
 	min, max        []byte
 	prefix          []byte
+	limit           int
+	less            func(a, b Storable) bool
+	batchSize       int
 	filters         []func(k, v []byte) (skip bool, stop bool)
 	storableFilters []func(obj Storable) (skip bool, stop bool)
+	skipBadRecords  bool
+	badRecords      *[]*BadRecord
 }
 
 func NewFilter() *Filter {
@@ -22,15 +28,34 @@ func (c *Filter) SetRange(min, max []byte) *Filter {
 }
 
 func (c *Filter) SetPrefix(prefix []byte) *Filter {
-	c.min = prefix
-	c.max = make([]byte, len(prefix))
-	copy(c.max, c.max)
-	for i := len(c.max) - 1; i >= 0; i-- {
-		if c.max[i] < 0xff {
-			c.max[i]++
-			break
-		}
-	}
+	c.prefix = prefix
+	return c
+}
+
+// SetLimit sets the max count of records to return, 0 means no limit.
+func (c *Filter) SetLimit(limit int) *Filter {
+	c.limit = limit
+	return c
+}
+
+// SetBatchSize makes DeleteWhere and UpdateWhere commit every batchSize records in separate transactions,
+// instead of a single transaction for all records, 0 means no batching.
+func (c *Filter) SetBatchSize(batchSize int) *Filter {
+	c.batchSize = batchSize
+	return c
+}
+
+// SortBy sorts the results by the exported field of the decoded objects, in descending order if desc is true.
+// Nested fields can be accessed with dots, like "Owner.Name", and records without the field are placed last.
+// With SetLimit, only the top records are kept in memory while scanning.
+func (c *Filter) SortBy(field string, desc ...bool) *Filter {
+	return c.SortFunc(fieldLess(field, len(desc) > 0 && desc[0]))
+}
+
+// SortFunc sorts the results with less, records which are equal keep the key order.
+// With SetLimit, only the top records are kept in memory while scanning.
+func (c *Filter) SortFunc(less func(a, b Storable) bool) *Filter {
+	c.less = less
 	return c
 }
 
@@ -44,6 +69,14 @@ func (c *Filter) AddStorableCondition(f func(obj Storable) (skip bool, stop bool
 	return c
 }
 
+// SkipBadRecords makes Scan, ScanMap, First and Count skip the records which can not be decoded,
+// instead of failing with the first one. If collected is not nil, the skipped records are appended to it.
+func (c *Filter) SkipBadRecords(collected *[]*BadRecord) *Filter {
+	c.skipBadRecords = true
+	c.badRecords = collected
+	return c
+}
+
 func (c *Filter) seek() []byte {
 	if c == nil {
 		return nil
@@ -54,6 +87,13 @@ func (c *Filter) seek() []byte {
 	return c.min
 }
 
+func (c *Filter) first(cur cursor) ([]byte, []byte) {
+	if seek := c.seek(); seek != nil {
+		return cur.Seek(seek)
+	}
+	return cur.First()
+}
+
 func (c *Filter) goon(k []byte) bool {
 	if k == nil {
 		return false
@@ -70,6 +110,47 @@ func (c *Filter) goon(k []byte) bool {
 	return true
 }
 
+func (c *Filter) getLimit() int {
+	if c == nil {
+		return 0
+	}
+	return c.limit
+}
+
+// resume returns a copy of the filter which starts after the key last and returns at most limit records.
+func (c *Filter) resume(last []byte, limit int) *Filter {
+	ret := &Filter{}
+	if c != nil {
+		*ret = *c
+	}
+	ret.limit = limit
+	if last != nil {
+		if bytes.Compare(last, ret.min) > 0 {
+			ret.min = last
+		}
+		ret.filters = append([]func(k, v []byte) (skip bool, stop bool){
+			func(k, v []byte) (skip bool, stop bool) {
+				return bytes.Equal(k, last), false
+			},
+		}, ret.filters...)
+	}
+	return ret
+}
+
+func (c *Filter) getBatchSize() int {
+	if c == nil {
+		return 0
+	}
+	return c.batchSize
+}
+
+func (c *Filter) getLess() func(a, b Storable) bool {
+	if c == nil {
+		return nil
+	}
+	return c.less
+}
+
 func (c *Filter) getConditions() []func(k, v []byte) (skip bool, stop bool) {
 	if c == nil {
 		return nil
@@ -84,12 +165,44 @@ func (c *Filter) getStorableConditions() []func(obj Storable) (skip bool, stop b
 	return c.storableFilters
 }
 
+func (c *Filter) getSkipBadRecords() bool {
+	if c == nil {
+		return false
+	}
+	return c.skipBadRecords
+}
+
+// skipBadRecord reports whether the record of err should be skipped, and collects it if it should.
+func (c *Filter) skipBadRecord(bucket, key []byte, err error) bool {
+	if !c.getSkipBadRecords() {
+		return false
+	}
+	var codecErr *codecError
+	if !errors.As(err, &codecErr) {
+		return false
+	}
+	if c.badRecords != nil {
+		var opErr *OpError
+		if errors.As(err, &opErr) {
+			err = opErr.Err
+		}
+		*c.badRecords = append(*c.badRecords, &BadRecord{
+			Bucket: bytes.Clone(bucket),
+			Key:    bytes.Clone(key),
+			Err:    err,
+		})
+	}
+	return true
+}
+
 type Condition struct {
 	ignoreIfExist bool // for Put
 	failIfExist   bool // for Put
 
 	ignoreIfNotExist bool // for Get
 	failIfNotExist   bool // for Put, Delete
+
+	loadDeleted bool // for Delete
 }
 
 func NewCondition() *Condition {
@@ -132,6 +245,16 @@ func (c *Condition) FailIfNotExist(v ...bool) *Condition {
 	return c
 }
 
+// LoadDeleted makes Delete load the previous value into the object before deleting it.
+func (c *Condition) LoadDeleted(v ...bool) *Condition {
+	if len(v) == 0 {
+		c.loadDeleted = true
+	} else {
+		c.loadDeleted = v[0]
+	}
+	return c
+}
+
 func (c *Condition) getIgnoreIfExist() bool {
 	if c == nil {
 		return false
@@ -159,3 +282,10 @@ func (c *Condition) getFailIfNotExist() bool {
 	}
 	return c.failIfNotExist
 }
+
+func (c *Condition) getLoadDeleted() bool {
+	if c == nil {
+		return false
+	}
+	return c.loadDeleted
+}
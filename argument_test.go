@@ -7,6 +7,38 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestFilter_Range(t *testing.T) {
+	db := testDB(t, true)
+	defer db.Close()
+	require.NoError(t, db.MPut(
+		&Person{Id: "aa"},
+		&Person{Id: "ab"},
+		&Person{Id: "b"},
+		&Person{Id: "c"},
+	))
+	ids := func(filter *Filter) []string {
+		var persons []*Person
+		require.NoError(t, db.Scan(&persons, filter))
+		var ret []string
+		for _, v := range persons {
+			ret = append(ret, v.Id)
+		}
+		return ret
+	}
+
+	assert.Equal(t, []string{"aa", "ab"}, ids(NewFilter().SetPrefix([]byte("a"))))
+	assert.Equal(t, []string{"ab"}, ids(NewFilter().SetPrefix([]byte("ab"))))
+	assert.Equal(t, []string{"b", "c"}, ids(NewFilter().SetRange([]byte("b"), nil)))
+	assert.Equal(t, []string{"ab", "b"}, ids(NewFilter().SetRange([]byte("ab"), []byte("b"))))
+
+	person := &Person{}
+	require.NoError(t, db.First(person, NewFilter().SetRange([]byte("b"), nil)))
+	assert.Equal(t, "b", person.Id)
+	count, err := db.Count(&Person{}, NewFilter().SetPrefix([]byte("a")))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
 func TestCondition(t *testing.T) {
 	t.Run("ignoreIfExist Put", func(t *testing.T) {
 		db := testDB(t, true)
@@ -96,8 +128,138 @@ func TestCondition(t *testing.T) {
 			Name: "name1",
 		}
 
-		assert.Error(t, db.Delete(person, NewCondition().FailIfNotExist(true)))
+		assert.ErrorIs(t, db.Delete(person, NewCondition().FailIfNotExist(true)), ErrBucketNotExist)
 
 		assert.NoError(t, db.Delete(person))
+
+		require.NoError(t, db.Put(&Person{Id: "id2"}))
+		assert.ErrorIs(t, db.Delete(person, NewCondition().FailIfNotExist(true)), ErrKeyNotExist)
+	})
+
+	t.Run("loadDeleted Delete", func(t *testing.T) {
+		db := testDB(t, true)
+		defer db.Close()
+		require.NoError(t, db.MPut(
+			&Person{Id: "id1", Name: "name1"},
+			&Person{Id: "id2", Name: "name2"},
+		))
+
+		person := &Person{Id: "id1"}
+		assert.NoError(t, db.Delete(person, NewCondition().LoadDeleted()))
+		assert.Equal(t, "name1", person.Name)
+		exist, err := db.Exist(person)
+		require.NoError(t, err)
+		assert.False(t, exist)
+
+		person = &Person{Id: "id1"}
+		assert.NoError(t, db.Delete(person, NewCondition().LoadDeleted()))
+		assert.Equal(t, "", person.Name)
+
+		person = &Person{Id: "id2"}
+		assert.NoError(t, db.GetAndDelete(person))
+		assert.Equal(t, "name2", person.Name)
+		assert.ErrorIs(t, db.GetAndDelete(person), ErrKeyNotExist)
+	})
+
+	t.Run("failIfExist MPut", func(t *testing.T) {
+		db := testDB(t, true)
+		defer db.Close()
+		require.NoError(t, db.Put(&Person{Id: "id2", Name: "name2"}))
+
+		err := db.MPutWithCondition(NewCondition().FailIfExist(),
+			&Person{Id: "id1", Name: "name1"},
+			&Person{Id: "id2", Name: "name2 again"},
+			&Person{Id: "id3", Name: "name3"},
+		)
+		assert.ErrorIs(t, err, ErrAlreadyExist)
+		var opErr *OpError
+		require.ErrorAs(t, err, &opErr)
+		assert.Equal(t, []byte("id2"), opErr.Key)
+
+		count, err := db.Count(&Person{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		assert.NoError(t, db.MPutWithCondition(NewCondition().FailIfExist(),
+			&Person{Id: "id1", Name: "name1"},
+			&Person{Id: "id3", Name: "name3"},
+		))
+		count, err = db.Count(&Person{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
 	})
+
+	t.Run("ignoreIfExist MPut", func(t *testing.T) {
+		db := testDB(t, true)
+		defer db.Close()
+		require.NoError(t, db.Put(&Person{Id: "id1", Name: "name1"}))
+
+		assert.NoError(t, db.MPutWithCondition(NewCondition().IgnoreIfExist(),
+			&Person{Id: "id1", Name: "name1 again"},
+			&Person{Id: "id2", Name: "name2"},
+		))
+		person := &Person{Id: "id1"}
+		require.NoError(t, db.Get(person))
+		assert.Equal(t, "name1", person.Name)
+		person = &Person{Id: "id2"}
+		require.NoError(t, db.Get(person))
+		assert.Equal(t, "name2", person.Name)
+	})
+
+	t.Run("failIfNotExist MDelete", func(t *testing.T) {
+		db := testDB(t, true)
+		defer db.Close()
+		require.NoError(t, db.Put(&Person{Id: "id1", Name: "name1"}))
+
+		err := db.MDeleteWithCondition(NewCondition().FailIfNotExist(), &Person{Id: "id1"}, &Book{Id: "1"})
+		assert.ErrorIs(t, err, ErrBucketNotExist)
+		exist, err := db.Exist(&Person{Id: "id1"})
+		require.NoError(t, err)
+		assert.True(t, exist)
+
+		assert.NoError(t, db.MDeleteWithCondition(nil, &Person{Id: "id1"}, &Book{Id: "1"}))
+		exist, err = db.Exist(&Person{Id: "id1"})
+		require.NoError(t, err)
+		assert.False(t, exist)
+	})
+}
+
+func TestFilter_SkipBadRecords(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	var cars []*Car
+	assert.Error(t, db.Scan(&cars))
+
+	var collected []*BadRecord
+	cars = nil
+	require.NoError(t, db.Scan(&cars, NewFilter().SkipBadRecords(&collected)))
+	require.Len(t, cars, 1)
+	assert.Equal(t, "tesla", cars[0].Name)
+	require.Len(t, collected, 1)
+	assert.Equal(t, []byte("car"), collected[0].Bucket)
+	assert.Equal(t, (&Car{Id: 0}).BoltKey(), collected[0].Key)
+	assert.ErrorContains(t, collected[0].Err, "decode *boltutil.Car")
+
+	carMap := map[uint32]*Car{}
+	require.NoError(t, db.ScanMap(&carMap, NewFilter().SkipBadRecords(nil)))
+	assert.Len(t, carMap, 1)
+
+	car := &Car{}
+	assert.Error(t, db.First(car))
+	car = &Car{}
+	require.NoError(t, db.First(car, NewFilter().SkipBadRecords(nil)))
+	assert.Equal(t, "tesla", car.Name)
+	car = &Car{}
+	require.NoError(t, db.First(car, NewFilter().SkipBadRecords(nil).SortBy("Name")))
+	assert.Equal(t, "tesla", car.Name)
+
+	count, err := db.Count(&Car{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	collected = nil
+	count, err = db.Count(&Car{}, NewFilter().SkipBadRecords(&collected))
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Len(t, collected, 1)
 }
@@ -0,0 +1,255 @@
+package boltutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// AuditEntry is a change recorded in the audit bucket.
+type AuditEntry struct {
+	Seq    uint64    `json:"-"` // sequence of the entry, which is the key in the audit bucket
+	Time   time.Time `json:"time"`
+	Op     string    `json:"op"` // name of the operation, like "put" or "mdelete"
+	Bucket []byte    `json:"bucket"`
+	Key    []byte    `json:"key"`
+	Prev   []byte    `json:"prev,omitempty"` // encoded value before the change, nil if the key did not exist
+	New    []byte    `json:"new,omitempty"`  // encoded value after the change, nil if the key is deleted
+	Actor  string    `json:"actor,omitempty"`
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx with the actor, which is recorded in the audit entries of the changes made with it.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set with WithActor, or an empty string if there is none.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// auditIndexBucket returns the name of the bucket indexing the audit entries by bucket and key, see auditIndexKey.
+func (d *DB) auditIndexBucket() []byte {
+	return append(bytes.Clone(d.auditBucket), "_index"...)
+}
+
+// auditTimeBucket returns the name of the bucket indexing the audit entries by time, see auditTimeKey.
+func (d *DB) auditTimeBucket() []byte {
+	return append(bytes.Clone(d.auditBucket), "_time"...)
+}
+
+// isAuditBucket reports whether the bucket is the audit bucket or one of its indexes, which can not be deleted or moved.
+func (d *DB) isAuditBucket(name []byte) bool {
+	return d.auditBucket != nil && (bytes.Equal(name, d.auditBucket) ||
+		bytes.Equal(name, d.auditIndexBucket()) || bytes.Equal(name, d.auditTimeBucket()))
+}
+
+// checkAuditBucket fails with ErrInvalid if the bucket is the audit bucket or one of its indexes.
+func (d *DB) checkAuditBucket(name []byte) error {
+	if d.isAuditBucket(name) {
+		return &OpError{
+			Bucket: bytes.Clone(name),
+			Err:    fmt.Errorf("audit bucket is append-only: %w", ErrInvalid),
+		}
+	}
+	return nil
+}
+
+// auditIndexPrefix returns the prefix of the index keys of the key in the bucket, which is the uvarint length
+// of the bucket name, the bucket name, the uvarint length of the key and the key.
+func auditIndexPrefix(bucket, key []byte) []byte {
+	ret := binary.AppendUvarint(nil, uint64(len(bucket)))
+	ret = append(ret, bucket...)
+	ret = binary.AppendUvarint(ret, uint64(len(key)))
+	return append(ret, key...)
+}
+
+// auditIndexKey returns the index key of the entry, which is the prefix followed by the sequence,
+// so the entries of a key are sorted by sequence.
+func auditIndexKey(bucket, key []byte, seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(auditIndexPrefix(bucket, key), seq)
+}
+
+// auditTimeKey returns the time index key of the entry, which is the nanoseconds since the Unix epoch
+// followed by the sequence, so the entries are sorted by time, then by sequence.
+func auditTimeKey(t time.Time, seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(auditNanos(t), seq)
+}
+
+// auditNanos returns the nanoseconds since the Unix epoch in big-endian, a time before the epoch is the epoch.
+func auditNanos(t time.Time) []byte {
+	var nanos uint64
+	if t.After(time.Unix(0, 0)) {
+		nanos = uint64(t.UnixNano())
+	}
+	return binary.BigEndian.AppendUint64(nil, nanos)
+}
+
+// audit appends an entry of the change of the key and indexes it in the same transaction as the change,
+// it does nothing if the audit is disabled.
+func (d *DB) audit(op *operation, tx kvTx, name, key, prev, value []byte) error {
+	if d.auditBucket == nil {
+		return nil
+	}
+
+	audit, err := tx.createBucketIfNotExists(d.auditBucket)
+	if err != nil {
+		return err
+	}
+	index, err := tx.createBucketIfNotExists(d.auditIndexBucket())
+	if err != nil {
+		return err
+	}
+	timeIndex, err := tx.createBucketIfNotExists(d.auditTimeBucket())
+	if err != nil {
+		return err
+	}
+	seq, err := audit.NextSequence()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	data, err := json.Marshal(&AuditEntry{
+		Time:   now,
+		Op:     op.name,
+		Bucket: name,
+		Key:    key,
+		Prev:   prev,
+		New:    value,
+		Actor:  ActorFromContext(op.ctx),
+	})
+	if err != nil {
+		return err
+	}
+	if v, ok := audit.(*bbolt.Bucket); ok {
+		v.FillPercent = 1 // the keys are always appended
+	}
+	if err := audit.Put(auditKey(seq), data); err != nil {
+		return err
+	}
+	if err := index.Put(auditIndexKey(name, key, seq), auditKey(seq)); err != nil {
+		return err
+	}
+	return timeIndex.Put(auditTimeKey(now, seq), auditKey(seq))
+}
+
+func auditKey(seq uint64) []byte {
+	ret := make([]byte, 8)
+	binary.BigEndian.PutUint64(ret, seq)
+	return ret
+}
+
+// AuditByKey returns the audit entries of the key of obj in order of sequence.
+// It fails with ErrBucketNotExist if the audit is disabled or there is no entry yet.
+func (d *DB) AuditByKey(obj Storable) ([]*AuditEntry, error) {
+	return d.AuditByKeyContext(context.Background(), obj)
+}
+
+// AuditByKeyContext is like AuditByKey but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) AuditByKeyContext(ctx context.Context, obj Storable) (_ []*AuditEntry, err error) {
+	op := d.newOperation(ctx, "audit", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	if d.auditBucket == nil {
+		return nil, ErrBucketNotExist
+	}
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	audit, index := tx.Bucket(d.auditBucket), tx.Bucket(d.auditIndexBucket())
+	if audit == nil || index == nil {
+		return nil, ErrBucketNotExist
+	}
+	return d.auditByKey(op, audit, index.Cursor(), obj)
+}
+
+// auditByKey returns the audit entries of the key of obj with the cursor of the index.
+func (d *DB) auditByKey(op *operation, audit kvBucket, index cursor, obj Storable) ([]*AuditEntry, error) {
+	// the lengths are uvarint encoded, so no index key of another bucket or key has the prefix
+	prefix := auditIndexPrefix(obj.BoltBucket(), obj.BoltKey())
+	var ret []*AuditEntry
+	for k, v := index.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = index.Next() {
+		if err := op.ctx.Err(); err != nil {
+			return nil, err
+		}
+		entry, err := d.decodeAuditEntry(v, audit.Get(v))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+	return ret, nil
+}
+
+// AuditBetween returns the audit entries recorded in [from, to) in order of time, a zero to means no upper bound.
+// It fails with ErrBucketNotExist if the audit is disabled or there is no entry yet.
+func (d *DB) AuditBetween(from, to time.Time) ([]*AuditEntry, error) {
+	return d.AuditBetweenContext(context.Background(), from, to)
+}
+
+// AuditBetweenContext is like AuditBetween but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) AuditBetweenContext(ctx context.Context, from, to time.Time) (_ []*AuditEntry, err error) {
+	op := d.newOperation(ctx, "audit", d.auditBucket, nil)
+	defer op.finish(&err)
+
+	if d.auditBucket == nil {
+		return nil, ErrBucketNotExist
+	}
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	audit, index := tx.Bucket(d.auditBucket), tx.Bucket(d.auditTimeBucket())
+	if audit == nil || index == nil {
+		return nil, ErrBucketNotExist
+	}
+	return d.auditBetween(op, audit, index.Cursor(), from, to)
+}
+
+// auditBetween returns the audit entries recorded in [from, to) with the cursor of the time index.
+// The time index is seeked, since the time of the entries is not guaranteed to increase with the sequence.
+func (d *DB) auditBetween(op *operation, audit kvBucket, index cursor, from, to time.Time) ([]*AuditEntry, error) {
+	var end []byte
+	if !to.IsZero() {
+		end = auditNanos(to)
+	}
+	var ret []*AuditEntry
+	for k, v := index.Seek(auditNanos(from)); k != nil && (end == nil || bytes.Compare(k, end) < 0); k, v = index.Next() {
+		if err := op.ctx.Err(); err != nil {
+			return nil, err
+		}
+		entry, err := d.decodeAuditEntry(v, audit.Get(v))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+	return ret, nil
+}
+
+// decodeAuditEntry decodes the entry of the key in the audit bucket.
+func (d *DB) decodeAuditEntry(k, v []byte) (*AuditEntry, error) {
+	entry := &AuditEntry{}
+	if err := json.Unmarshal(v, entry); err != nil {
+		return nil, &OpError{
+			Bucket: bytes.Clone(d.auditBucket),
+			Key:    bytes.Clone(k),
+			Err:    newCodecError("decode", entry, err),
+		}
+	}
+	entry.Seq = binary.BigEndian.Uint64(k)
+	return entry, nil
+}
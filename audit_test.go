@@ -0,0 +1,258 @@
+package boltutil
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestDB_Audit(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithAuditBucket([]byte("audit")), WithDefaultCoder(JsonCoder{}))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.AuditByKey(&Person{Id: "jason"})
+	assert.ErrorIs(t, err, ErrBucketNotExist)
+
+	start := time.Now()
+	ctx := WithActor(context.Background(), "admin")
+	require.NoError(t, db.PutContext(ctx, &Person{Id: "jason", Name: "Jason"}))
+	require.NoError(t, db.MPutContext(ctx, &Person{Id: "jason", Name: "Jason Song"}, &Person{Id: "vivia", Name: "Vivia"}))
+	middle := time.Now()
+	require.NoError(t, db.Delete(&Person{Id: "jason"}))
+	require.NoError(t, db.MDelete(&Person{Id: "vivia"}, &Person{Id: "nobody"}))
+
+	entries, err := db.AuditByKey(&Person{Id: "jason"})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, uint64(1), entries[0].Seq)
+	assert.Equal(t, "put", entries[0].Op)
+	assert.Equal(t, []byte("person"), entries[0].Bucket)
+	assert.Equal(t, []byte("jason"), entries[0].Key)
+	assert.Nil(t, entries[0].Prev)
+	assert.JSONEq(t, `{"Id":"jason","Name":"Jason","Age":0}`, string(entries[0].New))
+	assert.Equal(t, "admin", entries[0].Actor)
+	assert.Equal(t, "mput", entries[1].Op)
+	assert.Equal(t, entries[0].New, entries[1].Prev)
+	assert.JSONEq(t, `{"Id":"jason","Name":"Jason Song","Age":0}`, string(entries[1].New))
+	assert.Equal(t, "delete", entries[2].Op)
+	assert.Equal(t, entries[1].New, entries[2].Prev)
+	assert.Nil(t, entries[2].New)
+	assert.Empty(t, entries[2].Actor)
+
+	entries, err = db.AuditBetween(start, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 5)
+	entries, err = db.AuditBetween(middle, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint64(4), entries[0].Seq)
+	assert.Equal(t, "mdelete", entries[1].Op)
+	assert.Equal(t, []byte("vivia"), entries[1].Key)
+	entries, err = db.AuditBetween(start, middle)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestDB_Audit_Rollback(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithAuditBucket([]byte("audit")))
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.Put(&Person{Id: "jason"}))
+	assert.ErrorIs(t, db.MPut(&Person{Id: "vivia"}, &Book{Id: "bad"}), ErrInvalid)
+
+	entries, err := db.AuditBetween(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, []byte("jason"), entries[0].Key)
+}
+
+func TestDB_Audit_Disabled(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	_, err := db.AuditBetween(time.Time{}, time.Time{})
+	assert.ErrorIs(t, err, ErrBucketNotExist)
+}
+
+func TestDB_Audit_Index(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithAuditBucket([]byte("audit")))
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.MPut(&Person{Id: "jason"}, &Person{Id: "jaso"}, &Wind{}))
+	require.NoError(t, db.Put(&Person{Id: "jason", Name: "Jason"}))
+	// only the indexed entries are decoded
+	require.NoError(t, db.Unwrap().Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("audit")).Put(auditKey(2), []byte("dirty test data"))
+	}))
+
+	entries, err := db.AuditByKey(&Person{Id: "jason"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint64(1), entries[0].Seq)
+	assert.Equal(t, uint64(4), entries[1].Seq)
+	entries, err = db.AuditByKey(&Person{Id: "jasonx"})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = db.AuditByKey(&Person{Id: "jaso"})
+	assert.ErrorContains(t, err, `audit bucket "audit" key "\x00\x00\x00\x00\x00\x00\x00\x02": decode *boltutil.AuditEntry`)
+	_, err = db.AuditBetween(time.Time{}, time.Time{})
+	assert.ErrorContains(t, err, `decode *boltutil.AuditEntry`)
+}
+
+func TestDB_Audit_Time(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithAuditBucket([]byte("audit")))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.AuditBetween(time.Time{}, time.Time{})
+	assert.ErrorIs(t, err, ErrBucketNotExist)
+	require.NoError(t, db.Put(&Person{Id: "jason"}))
+	middle := time.Now()
+	require.NoError(t, db.Put(&Person{Id: "vivia"}))
+
+	// move the first entry after the second one in the time index
+	require.NoError(t, db.Unwrap().Update(func(tx *bbolt.Tx) error {
+		index := tx.Bucket([]byte("audit_time"))
+		k, v := index.Cursor().First()
+		if err := index.Delete(k); err != nil {
+			return err
+		}
+		return index.Put(auditTimeKey(time.Now(), 1), v)
+	}))
+
+	entries, err := db.AuditBetween(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint64(2), entries[0].Seq)
+	assert.Equal(t, uint64(1), entries[1].Seq)
+	entries, err = db.AuditBetween(time.Time{}, middle)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	entries, err = db.AuditBetween(middle, time.Now())
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	entries, err = db.AuditBetween(time.Time{}, time.Unix(-1, 0))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDB_Audit_Buckets(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithAuditBucket([]byte("audit")))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Put(&Person{Id: "jason"}))
+
+	assert.ErrorIs(t, db.DeleteBucket(BucketName("audit")), ErrInvalid)
+	assert.ErrorIs(t, db.RenameBucket(BucketName("audit"), BucketName("other")), ErrInvalid)
+	assert.ErrorIs(t, db.RenameBucket(BucketName("person"), BucketName("audit_index")), ErrInvalid)
+	assert.ErrorIs(t, db.CopyBucket(BucketName("person"), BucketName("audit")), ErrInvalid)
+	require.NoError(t, db.DeleteAllBucket())
+
+	buckets, err := db.Buckets()
+	require.NoError(t, err)
+	assert.Equal(t, []BucketPath{{[]byte("audit")}, {[]byte("audit_index")}, {[]byte("audit_time")}}, buckets)
+	entries, err := db.AuditByKey(&Person{Id: "jason"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+// forgedAudit is stored in the audit bucket to check that it can not be written.
+type forgedAudit struct {
+	Seq uint64
+}
+
+func (f *forgedAudit) BoltBucket() []byte {
+	return []byte("audit")
+}
+
+func (f *forgedAudit) BoltKey() []byte {
+	return auditKey(f.Seq)
+}
+
+func TestDB_Audit_Write(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithAuditBucket([]byte("audit")))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Put(&Person{Id: "jason"}))
+
+	forged := &forgedAudit{Seq: 1}
+	t.Run("put", func(t *testing.T) {
+		assert.ErrorIs(t, db.Put(forged), ErrInvalid)
+		assert.ErrorIs(t, db.MPut(forged), ErrInvalid)
+		assert.ErrorIs(t, db.BatchPut(forged), ErrInvalid)
+	})
+	t.Run("delete", func(t *testing.T) {
+		assert.ErrorIs(t, db.Delete(forged), ErrInvalid)
+		assert.ErrorIs(t, db.MDelete(forged), ErrInvalid)
+		assert.ErrorIs(t, db.BatchDelete(forged), ErrInvalid)
+	})
+	t.Run("where", func(t *testing.T) {
+		_, err := db.DeleteWhere(forged, nil)
+		assert.ErrorIs(t, err, ErrInvalid)
+		_, err = db.UpdateWhere(forged, nil, func(obj Storable) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+	t.Run("cas", func(t *testing.T) {
+		_, err := db.CompareAndSwap(nil, &forgedAudit{Seq: 2})
+		assert.ErrorIs(t, err, ErrInvalid)
+		_, err = db.CompareAndDelete(forged)
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+	t.Run("incr", func(t *testing.T) {
+		_, err := db.Incr(BucketName("audit_index"), []byte("jason"), 1)
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+	t.Run("sequence", func(t *testing.T) {
+		_, err := db.NextSequence(BucketName("audit"))
+		assert.ErrorIs(t, err, ErrInvalid)
+		assert.ErrorIs(t, db.SetSequence(BucketName("audit_index"), 10), ErrInvalid)
+	})
+	t.Run("quarantine", func(t *testing.T) {
+		_, err := db.VerifyAndQuarantine(BucketName("audit"), &Person{})
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+	t.Run("memory", func(t *testing.T) {
+		m := NewMemDB(WithAuditBucket([]byte("audit")))
+		assert.ErrorIs(t, m.Put(forged), ErrInvalid)
+		assert.ErrorIs(t, m.Delete(forged), ErrInvalid)
+	})
+
+	entries, err := db.AuditBetween(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, []byte("person"), entries[0].Bucket)
+}
+
+func TestDB_Audit_Restore(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithAuditBucket([]byte("audit")))
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.Put(&Note{Id: "a", Text: "apple"}))
+	require.NoError(t, db.Delete(&Note{Id: "a"}))
+	require.NoError(t, db.RestoreContext(WithActor(context.Background(), "admin"), &Note{Id: "a"}))
+
+	entries, err := db.AuditByKey(&Note{Id: "a"})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "restore", entries[2].Op)
+	assert.Nil(t, entries[2].Prev)
+	assert.Equal(t, entries[1].Prev, entries[2].New)
+	assert.Equal(t, "admin", entries[2].Actor)
+}
+
+func TestActorFromContext(t *testing.T) {
+	assert.Empty(t, ActorFromContext(context.Background()))
+	assert.Equal(t, "admin", ActorFromContext(WithActor(context.Background(), "admin")))
+}
@@ -0,0 +1,67 @@
+package boltutil
+
+import (
+	"context"
+
+	"go.etcd.io/bbolt"
+)
+
+// BatchPut stores storable object as Put, but concurrent calls from many goroutines are coalesced
+// into fewer transactions with bbolt.DB.Batch, see WithMaxBatchSize and WithMaxBatchDelay.
+// The put may be retried if another call in the same batch fails, so the hooks may be called more than once.
+func (d *DB) BatchPut(obj Storable, conditions ...*Condition) error {
+	return d.BatchPutContext(context.Background(), obj, conditions...)
+}
+
+// BatchPutContext is like BatchPut but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) BatchPutContext(ctx context.Context, obj Storable, conditions ...*Condition) (err error) {
+	op := d.newOperation(ctx, "batch put", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	var condition *Condition
+	if len(conditions) == 1 {
+		condition = conditions[0]
+	} else if len(conditions) > 1 {
+		return ErrTooManyConditions
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.batch(func(tx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return d.putWithCondition(op, boltTx{tx}, obj, condition)
+	})
+}
+
+// BatchDelete deletes storable object as Delete, but concurrent calls from many goroutines are coalesced
+// into fewer transactions with bbolt.DB.Batch, see WithMaxBatchSize and WithMaxBatchDelay.
+// The delete may be retried if another call in the same batch fails, so the hooks may be called more than once.
+func (d *DB) BatchDelete(obj Storable, conditions ...*Condition) error {
+	return d.BatchDeleteContext(context.Background(), obj, conditions...)
+}
+
+// BatchDeleteContext is like BatchDelete but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) BatchDeleteContext(ctx context.Context, obj Storable, conditions ...*Condition) (err error) {
+	op := d.newOperation(ctx, "batch delete", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	var condition *Condition
+	if len(conditions) == 1 {
+		condition = conditions[0]
+	} else if len(conditions) > 1 {
+		return ErrTooManyConditions
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.batch(func(tx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return d.deleteWithCondition(op, boltTx{tx}, obj, condition)
+	})
+}
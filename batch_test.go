@@ -0,0 +1,77 @@
+package boltutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_BatchPut(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithMaxBatchSize(20), WithMaxBatchDelay(5*time.Millisecond))
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, 20, db.Unwrap().MaxBatchSize)
+	assert.Equal(t, 5*time.Millisecond, db.Unwrap().MaxBatchDelay)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := db.BatchPut(&Person{Id: fmt.Sprint(i), Age: i}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	count, err := db.Count(&Person{})
+	require.NoError(t, err)
+	assert.Equal(t, 100, count)
+
+	t.Run("condition", func(t *testing.T) {
+		assert.ErrorIs(t, db.BatchPut(&Person{Id: "1"}, NewCondition().FailIfExist()), ErrAlreadyExist)
+		assert.ErrorIs(t, db.BatchPut(&Person{Id: "1"}, nil, nil), ErrTooManyConditions)
+	})
+
+	t.Run("before put", func(t *testing.T) {
+		car := &Car{Name: "tesla"}
+		require.NoError(t, db.BatchPut(car))
+		assert.Equal(t, uint32(1), car.Id)
+	})
+}
+
+func TestDB_BatchDelete(t *testing.T) {
+	db := testDB(t, true)
+	defer db.Close()
+
+	var persons []Storable
+	for i := 0; i < 100; i++ {
+		persons = append(persons, &Person{Id: fmt.Sprint(i)})
+	}
+	require.NoError(t, db.MPut(persons...))
+
+	wg := sync.WaitGroup{}
+	for _, v := range persons {
+		wg.Add(1)
+		go func(obj Storable) {
+			defer wg.Done()
+			if err := db.BatchDelete(obj, NewCondition().FailIfNotExist()); err != nil {
+				t.Error(err)
+			}
+		}(v)
+	}
+	wg.Wait()
+
+	count, err := db.Count(&Person{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	assert.ErrorIs(t, db.BatchDelete(&Person{Id: "1"}, NewCondition().FailIfNotExist()), ErrKeyNotExist)
+	assert.ErrorIs(t, db.BatchDelete(&Person{Id: "1"}, nil, nil), ErrTooManyConditions)
+}
@@ -0,0 +1,199 @@
+package boltutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+// BucketName implements HasBucket with the name of a bucket,
+// it's useful to manage buckets which are not bound to a type.
+type BucketName []byte
+
+func (b BucketName) BoltBucket() []byte {
+	return b
+}
+
+// BucketPath is the names of a bucket and its parents, from the top level bucket to the bucket itself.
+type BucketPath [][]byte
+
+func (p BucketPath) String() string {
+	names := make([]string, 0, len(p))
+	for _, v := range p {
+		names = append(names, fmt.Sprintf("%q", v))
+	}
+	return strings.Join(names, "/")
+}
+
+// BucketStats is the statistics of a bucket.
+type BucketStats struct {
+	bbolt.BucketStats
+	Keys     int    // count of keys in the bucket, excluding nested buckets and the keys in them
+	Sequence uint64 // the current sequence of the bucket
+}
+
+// Buckets returns the paths of all buckets, including nested buckets, a bucket is listed before the buckets in it.
+func (d *DB) Buckets() ([]BucketPath, error) {
+	return d.BucketsContext(context.Background())
+}
+
+// BucketsContext is like Buckets but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) BucketsContext(ctx context.Context) (_ []BucketPath, err error) {
+	op := d.newOperation(ctx, "buckets", nil, nil)
+	defer op.finish(&err)
+
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	var ret []BucketPath
+	var walk func(path BucketPath, bucket *bbolt.Bucket) error
+	walk = func(path BucketPath, bucket *bbolt.Bucket) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ret = append(ret, path)
+		return bucket.ForEach(func(k, v []byte) error {
+			if v != nil {
+				return nil
+			}
+			return walk(append(path[:len(path):len(path)], bytes.Clone(k)), bucket.Bucket(k))
+		})
+	}
+	if err := tx.ForEach(func(name []byte, bucket *bbolt.Bucket) error {
+		return walk(BucketPath{bytes.Clone(name)}, bucket)
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// BucketStats returns the statistics of the bucket, fails with ErrBucketNotExist if it does not exist.
+func (d *DB) BucketStats(hasBucket HasBucket) (*BucketStats, error) {
+	return d.BucketStatsContext(context.Background(), hasBucket)
+}
+
+// BucketStatsContext is like BucketStats but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) BucketStatsContext(ctx context.Context, hasBucket HasBucket) (_ *BucketStats, err error) {
+	op := d.newOperation(ctx, "bucket stats", hasBucket.BoltBucket(), nil)
+	defer op.finish(&err)
+
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	bucket := tx.Bucket(hasBucket.BoltBucket())
+	if bucket == nil {
+		return nil, ErrBucketNotExist
+	}
+
+	ret := &BucketStats{
+		BucketStats: bucket.Stats(),
+		Sequence:    bucket.Sequence(),
+	}
+	cur := bucket.Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if v != nil {
+			ret.Keys++
+		}
+	}
+	return ret, nil
+}
+
+// RenameBucket renames the bucket from to the bucket to in a single transaction, including the nested buckets and the sequence.
+// It fails with ErrBucketNotExist if from does not exist, or ErrAlreadyExist if to exists.
+func (d *DB) RenameBucket(from, to HasBucket) error {
+	return d.RenameBucketContext(context.Background(), from, to)
+}
+
+// RenameBucketContext is like RenameBucket but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) RenameBucketContext(ctx context.Context, from, to HasBucket) (err error) {
+	op := d.newOperation(ctx, "rename bucket", from.BoltBucket(), nil)
+	defer op.finish(&err)
+
+	if err := d.checkAuditBucket(from.BoltBucket()); err != nil {
+		return err
+	}
+	return d.update(ctx, func(tx *bbolt.Tx) error {
+		if err := d.copyBucket(ctx, tx, from.BoltBucket(), to.BoltBucket()); err != nil {
+			return err
+		}
+		if err := tx.DeleteBucket(from.BoltBucket()); err != nil {
+			return err
+		}
+		d.invalidateBucket(tx, from.BoltBucket())
+		return nil
+	})
+}
+
+// CopyBucket copies the bucket from to the bucket to in a single transaction, including the nested buckets and the sequence.
+// It fails with ErrBucketNotExist if from does not exist, or ErrAlreadyExist if to exists.
+func (d *DB) CopyBucket(from, to HasBucket) error {
+	return d.CopyBucketContext(context.Background(), from, to)
+}
+
+// CopyBucketContext is like CopyBucket but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) CopyBucketContext(ctx context.Context, from, to HasBucket) (err error) {
+	op := d.newOperation(ctx, "copy bucket", from.BoltBucket(), nil)
+	defer op.finish(&err)
+
+	return d.update(ctx, func(tx *bbolt.Tx) error {
+		return d.copyBucket(ctx, tx, from.BoltBucket(), to.BoltBucket())
+	})
+}
+
+// copyBucket creates the bucket to with the content of the bucket from in tx.
+func (d *DB) copyBucket(ctx context.Context, tx *bbolt.Tx, from, to []byte) error {
+	if err := d.checkAuditBucket(to); err != nil {
+		return err
+	}
+	src := tx.Bucket(from)
+	if src == nil {
+		return ErrBucketNotExist
+	}
+	if tx.Bucket(to) != nil {
+		return &OpError{
+			Bucket: to,
+			Err:    ErrAlreadyExist,
+		}
+	}
+	dst, err := tx.CreateBucket(to)
+	if err != nil {
+		return &OpError{
+			Bucket: to,
+			Err:    err,
+		}
+	}
+	d.invalidateBucket(tx, to)
+	return copyBucketContent(ctx, dst, src)
+}
+
+// copyBucketContent copies the keys, the nested buckets and the sequence of src into dst.
+func copyBucketContent(ctx context.Context, dst, src *bbolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		child, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucketContent(ctx, child, src.Bucket(k))
+	})
+}
@@ -0,0 +1,125 @@
+package boltutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+// nest creates the nested buckets person/friend/close with a key in each.
+func nest(t *testing.T, db *DB) {
+	require.NoError(t, db.Unwrap().Update(func(tx *bbolt.Tx) error {
+		friend, err := tx.Bucket([]byte("person")).CreateBucket([]byte("friend"))
+		if err != nil {
+			return err
+		}
+		if err := friend.Put([]byte("jason"), []byte("vivia")); err != nil {
+			return err
+		}
+		closeFriend, err := friend.CreateBucket([]byte("close"))
+		if err != nil {
+			return err
+		}
+		return closeFriend.Put([]byte("vivia"), []byte("jason"))
+	}))
+}
+
+func TestDB_Buckets(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	nest(t, db)
+
+	got, err := db.Buckets()
+	require.NoError(t, err)
+	assert.Equal(t, []BucketPath{
+		{[]byte("car")},
+		{[]byte("person")},
+		{[]byte("person"), []byte("friend")},
+		{[]byte("person"), []byte("friend"), []byte("close")},
+	}, got)
+	assert.Equal(t, `"person"/"friend"/"close"`, got[3].String())
+
+	db = testDB(t, true)
+	defer db.Close()
+	got, err = db.Buckets()
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestDB_BucketStats(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	nest(t, db)
+	_, err := db.NextSequence(&Person{})
+	require.NoError(t, err)
+
+	got, err := db.BucketStats(&Person{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Keys)
+	assert.Equal(t, 6, got.KeyN) // keys of the nested buckets are counted too
+	assert.Equal(t, 3, got.BucketN)
+	assert.Equal(t, uint64(1), got.Sequence)
+
+	_, err = db.BucketStats(BucketName("dog"))
+	assert.ErrorIs(t, err, ErrBucketNotExist)
+	assert.EqualError(t, err, `bucket stats bucket "dog": bucket not exist`)
+}
+
+func TestDB_RenameBucket(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	nest(t, db)
+	require.NoError(t, db.SetSequence(&Person{}, 10))
+
+	require.NoError(t, db.RenameBucket(&Person{}, BucketName("people")))
+
+	exist, err := db.Exist(&Person{Id: "jason"})
+	require.NoError(t, err)
+	assert.False(t, exist)
+	got, err := db.Buckets()
+	require.NoError(t, err)
+	assert.Equal(t, []BucketPath{
+		{[]byte("car")},
+		{[]byte("people")},
+		{[]byte("people"), []byte("friend")},
+		{[]byte("people"), []byte("friend"), []byte("close")},
+	}, got)
+
+	stats, err := db.BucketStats(BucketName("people"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Keys)
+	assert.Equal(t, uint64(10), stats.Sequence)
+	require.NoError(t, db.Unwrap().View(func(tx *bbolt.Tx) error {
+		value := tx.Bucket([]byte("people")).Bucket([]byte("friend")).Bucket([]byte("close")).Get([]byte("vivia"))
+		assert.Equal(t, []byte("jason"), value)
+		return nil
+	}))
+
+	err = db.RenameBucket(&Person{}, BucketName("people"))
+	assert.ErrorIs(t, err, ErrBucketNotExist)
+	assert.EqualError(t, err, `rename bucket bucket "person": bucket not exist`)
+
+	err = db.RenameBucket(BucketName("people"), &Car{})
+	assert.ErrorIs(t, err, ErrAlreadyExist)
+	assert.EqualError(t, err, `rename bucket bucket "car": already exist`)
+}
+
+func TestDB_CopyBucket(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	nest(t, db)
+
+	require.NoError(t, db.CopyBucket(&Person{}, BucketName("people")))
+
+	for _, bucket := range []HasBucket{&Person{}, BucketName("people")} {
+		stats, err := db.BucketStats(bucket)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.Keys)
+		assert.Equal(t, 6, stats.KeyN)
+	}
+
+	assert.ErrorIs(t, db.CopyBucket(&Person{}, BucketName("people")), ErrAlreadyExist)
+	assert.ErrorIs(t, db.CopyBucket(BucketName("dog"), BucketName("cat")), ErrBucketNotExist)
+}
@@ -0,0 +1,267 @@
+package boltutil
+
+import (
+	"bytes"
+	"container/list"
+	"reflect"
+	"sync"
+
+	"go.etcd.io/bbolt"
+)
+
+// cache is a LRU cache of decoded objects keyed by bucket and key.
+// It is invalidated after the writes of DB are committed, writes made with the bbolt.DB directly are not tracked.
+type cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	generation uint64 // increased on every invalidation, to reject objects read before it
+	lru        *list.List
+	entries    map[cacheKey]*list.Element
+}
+
+type cacheKey struct {
+	bucket string
+	key    string
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	obj  reflect.Value // pointer to the decoded object
+	size int
+}
+
+// newCache returns a cache limited by maxEntries and maxBytes, 0 means unlimited,
+// it returns nil if both are 0 and there is no cache.
+func newCache(maxEntries, maxBytes int) *cache {
+	if maxEntries <= 0 && maxBytes <= 0 {
+		return nil
+	}
+	return &cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		entries:    map[cacheKey]*list.Element{},
+	}
+}
+
+// getGeneration returns the current generation, which should be got before the transaction to read the objects to set.
+func (c *cache) getGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// get copies the cached object into obj, returns false if it is not cached.
+func (c *cache) get(obj Storable) bool {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[cacheKey{bucket: string(obj.BoltBucket()), key: string(obj.BoltKey())}]
+	if !ok {
+		return false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if entry.obj.Type() != v.Type() {
+		return false
+	}
+	c.lru.MoveToFront(elem)
+	v.Elem().Set(cloneValue(entry.obj.Elem()))
+	return true
+}
+
+// set caches a copy of obj, it is ignored if the cache has been invalidated since the generation.
+func (c *cache) set(obj Storable, size int, generation uint64) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+	key := cacheKey{bucket: string(obj.BoltBucket()), key: string(obj.BoltKey())}
+	size += len(key.bucket) + len(key.key)
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+	entry := &cacheEntry{
+		key:  key,
+		obj:  cloneValue(v),
+		size: size,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += size
+	for (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// remove removes the object of the key in the bucket.
+func (c *cache) remove(bucket, key []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if elem, ok := c.entries[cacheKey{bucket: string(bucket), key: string(key)}]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// removeBucket removes all objects in the bucket.
+func (c *cache) removeBucket(bucket []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, elem := range c.entries {
+		if key.bucket == string(bucket) {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// purge removes all objects.
+func (c *cache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.lru.Init()
+	c.entries = map[cacheKey]*list.Element{}
+	c.bytes = 0
+}
+
+func (c *cache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
+
+// load injects obj from the cache and calls AfterGet of it, returns false if there is no cache or obj is not cached.
+func (d *DB) load(obj Storable) (bool, error) {
+	if d.cache == nil || !d.cache.get(obj) {
+		return false, nil
+	}
+	return true, afterGet(obj.BoltKey(), obj)
+}
+
+// getGeneration returns the generation of the cache, or 0 if there is no cache.
+func (d *DB) getGeneration() uint64 {
+	if d.cache == nil {
+		return 0
+	}
+	return d.cache.getGeneration()
+}
+
+// decodeCached decodes value into obj as decode, and caches a copy of it before AfterGet is called.
+// With the cache, obj is overwritten entirely rather than decoded into, so a hit and a miss inject the same object.
+func (d *DB) decodeCached(op *operation, value []byte, obj Storable, generation uint64) error {
+	v := reflect.ValueOf(obj)
+	if d.cache == nil || v.Kind() != reflect.Ptr || v.IsNil() {
+		return d.decode(op, obj.BoltKey(), value, obj)
+	}
+
+	decoded := reflect.New(v.Type().Elem()).Interface().(Storable)
+	op.addBytes(len(value))
+	if err := d.getCoder(decoded).Decode(bytes.NewReader(value), decoded); err != nil {
+		return newOpError(obj, newCodecError("decode", obj, err))
+	}
+	d.cache.set(decoded, len(value), generation)
+	v.Elem().Set(reflect.ValueOf(decoded).Elem())
+	return afterGet(obj.BoltKey(), obj)
+}
+
+// invalidate removes the object of the key in the bucket from the cache once tx is committed.
+func (d *DB) invalidate(tx *bbolt.Tx, bucket, key []byte) {
+	if d.cache == nil {
+		return
+	}
+	bucket, key = bytes.Clone(bucket), bytes.Clone(key)
+	tx.OnCommit(func() {
+		d.cache.remove(bucket, key)
+	})
+}
+
+// invalidateBucket removes all objects in the bucket from the cache once tx is committed, or all objects if bucket is nil.
+func (d *DB) invalidateBucket(tx *bbolt.Tx, bucket []byte) {
+	if d.cache == nil {
+		return
+	}
+	if bucket == nil {
+		tx.OnCommit(d.cache.purge)
+		return
+	}
+	bucket = bytes.Clone(bucket)
+	tx.OnCommit(func() {
+		d.cache.removeBucket(bucket)
+	})
+}
+
+// cloneValue returns a deep copy of v, so the cached objects are not shared with the callers.
+// Unexported fields are copied shallowly, which is enough for the objects decoded by coders.
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		ret := reflect.New(v.Type().Elem())
+		ret.Elem().Set(cloneValue(v.Elem()))
+		return ret
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		ret := reflect.New(v.Type()).Elem()
+		ret.Set(cloneValue(v.Elem()))
+		return ret
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		ret := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			ret.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return ret
+	case reflect.Array:
+		ret := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			ret.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return ret
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		ret := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			ret.SetMapIndex(cloneValue(iter.Key()), cloneValue(iter.Value()))
+		}
+		return ret
+	case reflect.Struct:
+		ret := reflect.New(v.Type()).Elem()
+		ret.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				ret.Field(i).Set(cloneValue(v.Field(i)))
+			}
+		}
+		return ret
+	}
+	return v
+}
@@ -0,0 +1,205 @@
+package boltutil
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestDB_Cache(t *testing.T) {
+	open := func(t *testing.T, options ...Option) *DB {
+		db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), options...)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+		require.NoError(t, db.MPut(
+			&Person{Id: "jason", Name: "Jason Song", Age: 25},
+			&Person{Id: "vivia", Name: "Vivia Lei", Age: 25},
+		))
+		return db
+	}
+	// overwrite changes the stored value behind the DB, so a cached object can be told from a decoded one.
+	overwrite := func(t *testing.T, db *DB, obj Storable) {
+		coder := GobCoder{}
+		require.NoError(t, db.Unwrap().Update(func(tx *bbolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(obj.BoltBucket())
+			if err != nil {
+				return err
+			}
+			buffer := &bytes.Buffer{}
+			if err := coder.Encode(buffer, obj); err != nil {
+				return err
+			}
+			return bucket.Put(obj.BoltKey(), buffer.Bytes())
+		}))
+	}
+	get := func(t *testing.T, db *DB, id string) *Person {
+		ret := &Person{Id: id}
+		require.NoError(t, db.Get(ret))
+		return ret
+	}
+
+	t.Run("hit", func(t *testing.T) {
+		db := open(t, WithCacheMaxEntries(10))
+		assert.Equal(t, "Jason Song", get(t, db, "jason").Name)
+
+		overwrite(t, db, &Person{Id: "jason", Name: "changed"})
+		got := get(t, db, "jason")
+		assert.Equal(t, "Jason Song", got.Name)
+
+		got.Name = "modified"
+		assert.Equal(t, "Jason Song", get(t, db, "jason").Name)
+
+		objs := []Storable{&Person{Id: "jason"}, &Person{Id: "vivia"}}
+		require.NoError(t, db.MGet(objs...))
+		assert.Equal(t, "Jason Song", objs[0].(*Person).Name)
+		assert.Equal(t, "Vivia Lei", objs[1].(*Person).Name)
+
+		result, err := db.MGetPartial([]Storable{&Person{Id: "jason"}, &Person{Id: "lily"}}, NewCondition().IgnoreIfNotExist())
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, false}, result.Found)
+	})
+
+	t.Run("no cache", func(t *testing.T) {
+		db := open(t)
+		assert.Equal(t, "Jason Song", get(t, db, "jason").Name)
+		overwrite(t, db, &Person{Id: "jason", Name: "changed"})
+		assert.Equal(t, "changed", get(t, db, "jason").Name)
+	})
+
+	invalidations := []struct {
+		name  string
+		write func(db *DB) error
+		want  string // name of jason after the write, "" if it is deleted
+	}{
+		{
+			name: "put",
+			write: func(db *DB) error {
+				return db.Put(&Person{Id: "jason", Name: "put"})
+			},
+			want: "put",
+		},
+		{
+			name: "mput",
+			write: func(db *DB) error {
+				return db.MPut(&Person{Id: "vivia"}, &Person{Id: "jason", Name: "mput"})
+			},
+			want: "mput",
+		},
+		{
+			name: "delete",
+			write: func(db *DB) error {
+				return db.Delete(&Person{Id: "jason"})
+			},
+		},
+		{
+			name: "mdelete",
+			write: func(db *DB) error {
+				return db.MDelete(&Person{Id: "vivia"}, &Person{Id: "jason"})
+			},
+		},
+		{
+			name: "delete bucket",
+			write: func(db *DB) error {
+				return db.DeleteBucket(&Person{})
+			},
+		},
+		{
+			name: "delete all bucket",
+			write: func(db *DB) error {
+				return db.DeleteAllBucket()
+			},
+		},
+		{
+			name: "delete where",
+			write: func(db *DB) error {
+				_, err := db.DeleteWhere(&Person{}, NewFilter().SetPrefix([]byte("j")))
+				return err
+			},
+		},
+	}
+	for _, tt := range invalidations {
+		t.Run("invalidate by "+tt.name, func(t *testing.T) {
+			db := open(t, WithCacheMaxEntries(10))
+			assert.Equal(t, "Jason Song", get(t, db, "jason").Name)
+
+			require.NoError(t, tt.write(db))
+			got := &Person{Id: "jason"}
+			err := db.Get(got)
+			if tt.want == "" {
+				assert.ErrorIs(t, err, ErrNotExist)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Name)
+		})
+	}
+
+	t.Run("rollback", func(t *testing.T) {
+		db := open(t, WithCacheMaxEntries(10))
+		assert.Equal(t, "Jason Song", get(t, db, "jason").Name)
+
+		overwrite(t, db, &Person{Id: "jason", Name: "changed"})
+		assert.ErrorIs(t, db.MPutWithCondition(NewCondition().FailIfExist(), &Person{Id: "jason"}), ErrAlreadyExist)
+		assert.Equal(t, "Jason Song", get(t, db, "jason").Name)
+	})
+
+	t.Run("evict by entries", func(t *testing.T) {
+		db := open(t, WithCacheMaxEntries(1))
+		get(t, db, "jason")
+		get(t, db, "vivia")
+
+		overwrite(t, db, &Person{Id: "jason", Name: "changed"})
+		overwrite(t, db, &Person{Id: "vivia", Name: "changed"})
+		assert.Equal(t, "changed", get(t, db, "jason").Name)
+		assert.Equal(t, "changed", get(t, db, "vivia").Name)
+		assert.Equal(t, 1, db.cache.lru.Len())
+	})
+
+	t.Run("evict by bytes", func(t *testing.T) {
+		db := open(t, WithCacheMaxBytes(1))
+		get(t, db, "jason")
+		assert.Equal(t, 0, db.cache.lru.Len())
+	})
+}
+
+func Test_cloneValue(t *testing.T) {
+	type inner struct {
+		Values []int
+	}
+	type item struct {
+		Name    string
+		Tags    []string
+		Attrs   map[string]*inner
+		Pointer *inner
+		Any     any
+		Array   [2]*inner
+	}
+	src := &item{
+		Name:    "name",
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]*inner{"x": {Values: []int{1}}},
+		Pointer: &inner{Values: []int{2}},
+		Any:     &inner{Values: []int{3}},
+		Array:   [2]*inner{{Values: []int{4}}},
+	}
+	got := cloneValue(reflect.ValueOf(src)).Interface().(*item)
+	assert.Equal(t, src, got)
+
+	got.Tags[0] = "c"
+	got.Attrs["x"].Values[0] = 0
+	got.Pointer.Values[0] = 0
+	got.Any.(*inner).Values[0] = 0
+	got.Array[0].Values[0] = 0
+	assert.Equal(t, "a", src.Tags[0])
+	assert.Equal(t, 1, src.Attrs["x"].Values[0])
+	assert.Equal(t, 2, src.Pointer.Values[0])
+	assert.Equal(t, 3, src.Any.(*inner).Values[0])
+	assert.Equal(t, 4, src.Array[0].Values[0])
+}
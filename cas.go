@@ -0,0 +1,96 @@
+package boltutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// CompareAndSwap stores new only if the stored value of old's key equals to old encoded with its coder,
+// it reports whether new is stored. old can be nil to store new only if the key does not exist.
+// old and new should have the same bucket and key, and the coder should encode equal objects into equal bytes.
+func (d *DB) CompareAndSwap(old, new Storable) (bool, error) {
+	return d.CompareAndSwapContext(context.Background(), old, new)
+}
+
+// CompareAndSwapContext is like CompareAndSwap but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) CompareAndSwapContext(ctx context.Context, old, new Storable) (swapped bool, err error) {
+	op := d.newOperation(ctx, "compare and swap", new.BoltBucket(), new.BoltKey())
+	defer op.finish(&err)
+
+	if err := d.checkAuditBucket(new.BoltBucket()); err != nil {
+		return false, err
+	}
+	if old != nil && (!bytes.Equal(old.BoltBucket(), new.BoltBucket()) || !bytes.Equal(old.BoltKey(), new.BoltKey())) {
+		return false, fmt.Errorf("different bucket or key: %q %q", old.BoltBucket(), old.BoltKey())
+	}
+
+	var want []byte
+	if old != nil {
+		buffer := &bytes.Buffer{}
+		if err := d.getCoder(old).Encode(buffer, old); err != nil {
+			return false, newCodecError("encode", old, err)
+		}
+		want = buffer.Bytes()
+	}
+
+	tx, err := d.begin(ctx, true)
+	if err != nil {
+		return false, err
+	}
+	defer rollback(tx)
+
+	bucket, err := tx.CreateBucketIfNotExists(new.BoltBucket())
+	if err != nil {
+		return false, err
+	}
+	got := bucket.Get(new.BoltKey())
+	if (old == nil) != (got == nil) || !bytes.Equal(got, want) {
+		return false, nil
+	}
+
+	if err := d.put(op, boltTx{tx}, bucket, new); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// CompareAndDelete deletes old only if the stored value of its key equals to old encoded with its coder,
+// it reports whether old is deleted. The coder should encode equal objects into equal bytes.
+func (d *DB) CompareAndDelete(old Storable) (bool, error) {
+	return d.CompareAndDeleteContext(context.Background(), old)
+}
+
+// CompareAndDeleteContext is like CompareAndDelete but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) CompareAndDeleteContext(ctx context.Context, old Storable) (deleted bool, err error) {
+	op := d.newOperation(ctx, "compare and delete", old.BoltBucket(), old.BoltKey())
+	defer op.finish(&err)
+
+	if err := d.checkAuditBucket(old.BoltBucket()); err != nil {
+		return false, err
+	}
+	buffer := &bytes.Buffer{}
+	if err := d.getCoder(old).Encode(buffer, old); err != nil {
+		return false, newCodecError("encode", old, err)
+	}
+
+	tx, err := d.begin(ctx, true)
+	if err != nil {
+		return false, err
+	}
+	defer rollback(tx)
+
+	bucket := tx.Bucket(old.BoltBucket())
+	if bucket == nil {
+		return false, nil
+	}
+	got := bucket.Get(old.BoltKey())
+	if got == nil || !bytes.Equal(got, buffer.Bytes()) {
+		return false, nil
+	}
+
+	if err := d.delete(op, boltTx{tx}, bucket, old.BoltKey(), old); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
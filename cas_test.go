@@ -0,0 +1,100 @@
+package boltutil
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_CompareAndSwap(t *testing.T) {
+	t.Run("regular", func(t *testing.T) {
+		db := testDB(t, true)
+		defer db.Close()
+
+		swapped, err := db.CompareAndSwap(nil, &Person{Id: "id1", Name: "name1"})
+		require.NoError(t, err)
+		assert.True(t, swapped)
+
+		swapped, err = db.CompareAndSwap(nil, &Person{Id: "id1", Name: "name1 again"})
+		require.NoError(t, err)
+		assert.False(t, swapped)
+
+		swapped, err = db.CompareAndSwap(&Person{Id: "id1", Name: "wrong"}, &Person{Id: "id1", Name: "name2"})
+		require.NoError(t, err)
+		assert.False(t, swapped)
+
+		swapped, err = db.CompareAndSwap(&Person{Id: "id1", Name: "name1"}, &Person{Id: "id1", Name: "name2"})
+		require.NoError(t, err)
+		assert.True(t, swapped)
+
+		person := &Person{Id: "id1"}
+		require.NoError(t, db.Get(person))
+		assert.Equal(t, "name2", person.Name)
+
+		swapped, err = db.CompareAndSwap(&Person{Id: "id2"}, &Person{Id: "id2", Name: "name2"})
+		require.NoError(t, err)
+		assert.False(t, swapped)
+
+		_, err = db.CompareAndSwap(&Person{Id: "id1"}, &Person{Id: "id2"})
+		assert.Error(t, err)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		db := testDB(t, true)
+		defer db.Close()
+		require.NoError(t, db.Put(&Person{Id: "counter"}))
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; {
+					old := &Person{Id: "counter"}
+					if err := db.Get(old); err != nil {
+						t.Error(err)
+						return
+					}
+					swapped, err := db.CompareAndSwap(old, &Person{Id: "counter", Age: old.Age + 1})
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if swapped {
+						j++
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		person := &Person{Id: "counter"}
+		require.NoError(t, db.Get(person))
+		assert.Equal(t, 100, person.Age)
+	})
+}
+
+func TestDB_CompareAndDelete(t *testing.T) {
+	db := testDB(t, true)
+	defer db.Close()
+
+	deleted, err := db.CompareAndDelete(&Person{Id: "id1", Name: "name1"})
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	require.NoError(t, db.Put(&Person{Id: "id1", Name: "name1"}))
+
+	deleted, err = db.CompareAndDelete(&Person{Id: "id1", Name: "wrong"})
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	deleted, err = db.CompareAndDelete(&Person{Id: "id1", Name: "name1"})
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	exist, err := db.Exist(&Person{Id: "id1"})
+	require.NoError(t, err)
+	assert.False(t, exist)
+}
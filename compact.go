@@ -0,0 +1,359 @@
+package boltutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"go.etcd.io/bbolt"
+)
+
+type compactOption struct {
+	TxMaxSize int64
+	Coder     Coder
+	Types     []Storable
+}
+
+// CompactOption represents the options of compaction.
+type CompactOption func(options *compactOption)
+
+// WithCompactTxMaxSize return CompactOption with specified TxMaxSize,
+// the new file is committed every TxMaxSize bytes copied, 0 means copying in a single transaction
+func WithCompactTxMaxSize(txMaxSize int64) CompactOption {
+	return func(options *compactOption) {
+		options.TxMaxSize = txMaxSize
+	}
+}
+
+// WithCompactCoder return CompactOption with specified Coder and Types, the values in the buckets of the types,
+// including their soft deleted records and the values in their audit entries, are decoded with the coder of the DB and encoded with the Coder, so the new file should be opened with WithDefaultCoder.
+// The types should not implement HasCoder, since they are always decoded with their own coders.
+func WithCompactCoder(coder Coder, types ...Storable) CompactOption {
+	return func(options *compactOption) {
+		options.Coder = coder
+		options.Types = types
+	}
+}
+
+// CompactTo copies all buckets, including the nested buckets and the sequences, into a new file at path,
+// which is smaller than the current one if many records have been deleted. It fails if the file exists.
+func (d *DB) CompactTo(path string, options ...CompactOption) error {
+	return d.CompactToContext(context.Background(), path, options...)
+}
+
+// CompactToContext is like CompactTo but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) CompactToContext(ctx context.Context, path string, options ...CompactOption) (err error) {
+	op := d.newOperation(ctx, "compact", nil, nil)
+	defer op.finish(&err)
+
+	return d.compactTo(ctx, path, func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		return os.OpenFile(name, flag|os.O_EXCL, perm)
+	}, options)
+}
+
+// Compact compacts the database file in place, it copies the database into a temporary file with CompactTo,
+// then replaces the database file with it atomically and reopens the database.
+// With WithCompactCoder, the default coder of the DB is replaced by the new coder.
+// The other operations wait until it finishes, so no write is lost while the database is closed and reopened,
+// and it should not be called in the hooks.
+// If the database can not be reopened, the DB is unusable and every operation fails with the returned error.
+func (d *DB) Compact(options ...CompactOption) error {
+	return d.CompactContext(context.Background(), options...)
+}
+
+// CompactContext is like Compact but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) CompactContext(ctx context.Context, options ...CompactOption) (err error) {
+	op := d.newExclusiveOperation(ctx, "compact", nil, nil)
+	defer op.finish(&err)
+
+	if d.broken != nil {
+		return d.broken
+	}
+	path := d.db.Path()
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".compact-*")
+	if err != nil {
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if err := d.compactTo(ctx, temp.Name(), nil, options); err != nil {
+		return err
+	}
+
+	fileMode := d.fileMode
+	if fileMode == 0 {
+		fileMode = 0600
+	}
+	// the temporary file is created with 0600, and bbolt does not change the mode of an existing file
+	if err := os.Chmod(temp.Name(), fileMode); err != nil {
+		return err
+	}
+	maxBatchSize, maxBatchDelay := d.db.MaxBatchSize, d.db.MaxBatchDelay
+	if err := d.db.Close(); err != nil {
+		return err
+	}
+	// reopen the database even if the file is not replaced
+	renameErr := os.Rename(temp.Name(), path)
+	db, err := bbolt.Open(path, fileMode, d.options)
+	if err != nil {
+		d.broken = fmt.Errorf("database is unusable since it can not be reopened after compaction: %w", err)
+		return d.broken
+	}
+	db.MaxBatchSize, db.MaxBatchDelay = maxBatchSize, maxBatchDelay
+	d.db = db
+	if renameErr != nil {
+		return renameErr
+	}
+
+	option := newCompactOption(options)
+	if option.Coder != nil {
+		d.defaultCoder = option.Coder
+	}
+	if d.cache != nil {
+		d.cache.purge()
+	}
+	return nil
+}
+
+func newCompactOption(options []CompactOption) *compactOption {
+	option := &compactOption{}
+	for _, v := range options {
+		v(option)
+	}
+	return option
+}
+
+// compactTo copies the database into the file at path, which is opened with openFile, and removed if it fails.
+func (d *DB) compactTo(ctx context.Context, path string, openFile func(string, int, os.FileMode) (*os.File, error),
+	options []CompactOption) (err error) {
+	option := newCompactOption(options)
+	c := &compactor{
+		ctx:       ctx,
+		d:         d,
+		txMaxSize: option.TxMaxSize,
+		coder:     option.Coder,
+		types:     map[string]reflect.Type{},
+	}
+	if option.Coder != nil {
+		for _, v := range option.Types {
+			if _, ok := v.(HasCoder); ok {
+				return fmt.Errorf("re-encode %T which has its own coder", v)
+			}
+			c.types[string(v.BoltBucket())] = reflect.TypeOf(v).Elem()
+		}
+	}
+
+	fileMode := d.fileMode
+	if fileMode == 0 {
+		fileMode = 0600
+	}
+	dst, err := bbolt.Open(path, fileMode, &bbolt.Options{
+		OpenFile: openFile,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(path)
+		}
+	}()
+
+	c.dst = dst
+
+	src, err := d.begin(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer rollback(src)
+
+	if c.tx, err = dst.Begin(true); err != nil {
+		return err
+	}
+	defer func() {
+		rollback(c.tx)
+	}()
+
+	if err := src.ForEach(func(name []byte, bucket *bbolt.Bucket) error {
+		return c.walk([][]byte{name}, bucket)
+	}); err != nil {
+		return err
+	}
+	return c.tx.Commit()
+}
+
+// compactor copies buckets into the new database, committing every txMaxSize bytes.
+type compactor struct {
+	ctx       context.Context
+	d         *DB
+	dst       *bbolt.DB
+	tx        *bbolt.Tx
+	size      int64
+	txMaxSize int64
+	coder     Coder
+	types     map[string]reflect.Type // types to re-encode by top level bucket
+}
+
+// walk copies the bucket at path, including its nested buckets and sequence.
+func (c *compactor) walk(path [][]byte, src *bbolt.Bucket) error {
+	if err := c.grow(int64(len(path[len(path)-1]))); err != nil {
+		return err
+	}
+	var dst *bbolt.Bucket
+	var err error
+	if len(path) == 1 {
+		dst, err = c.tx.CreateBucket(path[0])
+	} else {
+		dst, err = c.bucket(path[:len(path)-1]).CreateBucket(path[len(path)-1])
+	}
+	if err != nil {
+		return err
+	}
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+
+	var itemType reflect.Type
+	isTombstones := len(path) == 1 && bytes.Equal(path[0], tombstoneBucket)
+	isAudit := len(path) == 1 && c.d.auditBucket != nil && bytes.Equal(path[0], c.d.auditBucket)
+	if len(path) == 1 {
+		itemType = c.types[string(path[0])]
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		if v == nil {
+			return c.walk(append(path[:len(path):len(path)], k), src.Bucket(k))
+		}
+
+		if itemType != nil {
+			var err error
+			if v, err = c.encode(itemType, k, v); err != nil {
+				return err
+			}
+		} else if isTombstones && len(c.types) > 0 {
+			var err error
+			if v, err = c.encodeTombstone(k, v); err != nil {
+				return err
+			}
+		} else if isAudit && len(c.types) > 0 {
+			var err error
+			if v, err = c.encodeAudit(k, v); err != nil {
+				return err
+			}
+		}
+		if err := c.grow(int64(len(k) + len(v))); err != nil {
+			return err
+		}
+		bucket := c.bucket(path)
+		bucket.FillPercent = 1
+		return bucket.Put(k, v)
+	})
+}
+
+// encode decodes the value with the coder of the DB and encodes it with the new coder.
+func (c *compactor) encode(itemType reflect.Type, k, v []byte) ([]byte, error) {
+	obj := reflect.New(itemType).Interface().(Storable)
+	if err := c.d.getCoder(obj).Decode(bytes.NewReader(v), obj); err != nil {
+		return nil, &OpError{
+			Bucket: obj.BoltBucket(),
+			Key:    bytes.Clone(k),
+			Err:    newCodecError("decode", obj, err),
+		}
+	}
+	buffer := &bytes.Buffer{}
+	if err := c.coder.Encode(buffer, obj); err != nil {
+		return nil, &OpError{
+			Bucket: obj.BoltBucket(),
+			Key:    bytes.Clone(k),
+			Err:    newCodecError("encode", obj, err),
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// encodeTombstone re-encodes the soft deleted record if its bucket is of the types to re-encode,
+// the time of deletion is kept.
+func (c *compactor) encodeTombstone(k, v []byte) ([]byte, error) {
+	bucket, key, err := splitTombstoneKey(k)
+	if err != nil {
+		return nil, err
+	}
+	itemType := c.types[string(bucket)]
+	if itemType == nil {
+		return v, nil
+	}
+	deletedAt, value, err := decodeTombstone(v)
+	if err != nil {
+		return nil, &OpError{
+			Bucket: bytes.Clone(tombstoneBucket),
+			Key:    bytes.Clone(k),
+			Err:    err,
+		}
+	}
+	if value, err = c.encode(itemType, key, value); err != nil {
+		return nil, err
+	}
+	return encodeTombstone(deletedAt, value), nil
+}
+
+// encodeAudit re-encodes the values before and after the change in the audit entry
+// if its bucket is of the types to re-encode, the other fields are kept.
+func (c *compactor) encodeAudit(k, v []byte) ([]byte, error) {
+	entry, err := c.d.decodeAuditEntry(k, v)
+	if err != nil {
+		return nil, err
+	}
+	itemType := c.types[string(entry.Bucket)]
+	if itemType == nil {
+		return v, nil
+	}
+	if entry.Prev != nil {
+		if entry.Prev, err = c.encode(itemType, entry.Key, entry.Prev); err != nil {
+			return nil, err
+		}
+	}
+	if entry.New != nil {
+		if entry.New, err = c.encode(itemType, entry.Key, entry.New); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(entry)
+}
+
+// grow commits the transaction and starts a new one if it would exceed txMaxSize with n bytes more.
+func (c *compactor) grow(n int64) error {
+	if c.txMaxSize > 0 && c.size+n > c.txMaxSize {
+		if err := c.tx.Commit(); err != nil {
+			return err
+		}
+		tx, err := c.dst.Begin(true)
+		if err != nil {
+			return err
+		}
+		c.tx = tx
+		c.size = 0
+	}
+	c.size += n
+	return nil
+}
+
+// bucket returns the bucket at path in the current transaction.
+func (c *compactor) bucket(path [][]byte) *bbolt.Bucket {
+	bucket := c.tx.Bucket(path[0])
+	for _, name := range path[1:] {
+		bucket = bucket.Bucket(name)
+	}
+	return bucket
+}
@@ -0,0 +1,258 @@
+package boltutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+// fragmentedDB returns a database with many deleted records, the nested buckets of nest and a sequence.
+func fragmentedDB(t *testing.T) *DB {
+	db := testDB(t)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	var objs []Storable
+	for i := 0; i < 1000; i++ {
+		objs = append(objs, &Person{Id: fmt.Sprintf("temp%04d", i), Name: "temporary person"})
+	}
+	require.NoError(t, db.MPut(objs...))
+	require.NoError(t, db.MDelete(objs...))
+	nest(t, db)
+	require.NoError(t, db.SetSequence(&Person{}, 10))
+	return db
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	return info.Size()
+}
+
+func TestDB_CompactTo(t *testing.T) {
+	db := fragmentedDB(t)
+	want, err := db.Buckets()
+	require.NoError(t, err)
+
+	check := func(t *testing.T, compacted *DB) {
+		got, err := compacted.Buckets()
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		person := &Person{Id: "jason"}
+		require.NoError(t, compacted.Get(person))
+		assert.Equal(t, "Jason Song", person.Name)
+		stats, err := compacted.BucketStats(&Person{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.Keys)
+		assert.Equal(t, uint64(10), stats.Sequence)
+		require.NoError(t, compacted.Unwrap().View(func(tx *bbolt.Tx) error {
+			value := tx.Bucket([]byte("person")).Bucket([]byte("friend")).Bucket([]byte("close")).Get([]byte("vivia"))
+			assert.Equal(t, []byte("jason"), value)
+			return nil
+		}))
+	}
+
+	for _, txMaxSize := range []int64{0, 10} {
+		t.Run(fmt.Sprintf("tx max size %d", txMaxSize), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "compacted.db")
+			require.NoError(t, db.CompactTo(path, WithCompactTxMaxSize(txMaxSize)))
+			assert.Less(t, fileSize(t, path), fileSize(t, db.Unwrap().Path()))
+
+			compacted, err := Open(path)
+			require.NoError(t, err)
+			defer compacted.Close()
+			check(t, compacted)
+		})
+	}
+
+	t.Run("exist", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "compacted.db")
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0600))
+		assert.ErrorIs(t, db.CompactTo(path), os.ErrExist)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), data)
+	})
+
+	t.Run("re-encode", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "compacted.db")
+		require.NoError(t, db.CompactTo(path, WithCompactCoder(JsonCoder{}, &Person{})))
+
+		compacted, err := Open(path, WithDefaultCoder(JsonCoder{}))
+		require.NoError(t, err)
+		defer compacted.Close()
+		var persons []*Person
+		require.NoError(t, compacted.Scan(&persons, NewFilter().SetPrefix([]byte("jason"))))
+		require.Len(t, persons, 1)
+		assert.Equal(t, "Jason Song", persons[0].Name)
+	})
+
+	t.Run("re-encode failure", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "compacted.db")
+		assert.Error(t, db.CompactTo(path, WithCompactCoder(JsonCoder{}, &Car{})))
+		_, err := os.Stat(path)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		err = db.CompactTo(path, WithCompactCoder(JsonCoder{}, &wrongPerson{}))
+		assert.ErrorContains(t, err, `compact bucket "person" key "jason": decode *boltutil.wrongPerson`)
+		_, err = os.Stat(path)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestDB_Compact(t *testing.T) {
+	db := fragmentedDB(t)
+	require.NoError(t, db.Delete(&Car{Id: 0}))
+	path := db.Unwrap().Path()
+	size := fileSize(t, path)
+
+	require.NoError(t, db.Compact(WithCompactCoder(JsonCoder{}, &Person{})))
+	assert.Less(t, fileSize(t, path), size)
+	assert.Equal(t, path, db.Unwrap().Path())
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	person := &Person{Id: "jason"}
+	require.NoError(t, db.Get(person))
+	assert.Equal(t, "Jason Song", person.Name)
+	car := &Car{Id: 1}
+	require.NoError(t, db.Get(car))
+	assert.Equal(t, "tesla", car.Name)
+
+	require.NoError(t, db.Put(&Person{Id: "lily", Name: "Lily"}))
+	require.NoError(t, db.Unwrap().View(func(tx *bbolt.Tx) error {
+		assert.JSONEq(t, `{"Id":"lily","Name":"Lily","Age":0}`, string(tx.Bucket([]byte("person")).Get([]byte("lily"))))
+		return nil
+	}))
+}
+
+func TestDB_Compact_FileMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bolt.db")
+	db, err := Open(path, WithFileMode(0644))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, os.Chmod(path, 0644)) // regardless of umask
+	require.NoError(t, db.Put(&Person{Id: "jason", Name: "Jason Song"}))
+
+	require.NoError(t, db.Compact())
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestDB_Compact_SoftDeleted(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	require.NoError(t, db.MPut(&Note{Id: "a", Text: "apple"}, &Note{Id: "b", Text: "banana"}))
+	require.NoError(t, db.Delete(&Note{Id: "a"}))
+	var deletedAt time.Time
+	require.NoError(t, db.Unwrap().View(func(tx *bbolt.Tx) error {
+		var err error
+		deletedAt, _, err = decodeTombstone(tx.Bucket(tombstoneBucket).Get(tombstoneKey([]byte("note"), []byte("a"))))
+		return err
+	}))
+
+	require.NoError(t, db.Compact(WithCompactCoder(JsonCoder{}, &Note{})))
+
+	require.NoError(t, db.Unwrap().View(func(tx *bbolt.Tx) error {
+		got, value, err := decodeTombstone(tx.Bucket(tombstoneBucket).Get(tombstoneKey([]byte("note"), []byte("a"))))
+		require.NoError(t, err)
+		assert.True(t, deletedAt.Equal(got))
+		assert.JSONEq(t, `{"Id":"a","Text":"apple"}`, string(value))
+		return nil
+	}))
+	note := &Note{Id: "a"}
+	require.NoError(t, db.Restore(note))
+	assert.Equal(t, "apple", note.Text)
+}
+
+func TestDB_Compact_Audit(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithAuditBucket([]byte("audit")))
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := WithActor(context.Background(), "admin")
+	require.NoError(t, db.PutContext(ctx, &Person{Id: "jason", Name: "Jason"}))
+	require.NoError(t, db.Put(&Person{Id: "jason", Name: "Jason Song"}))
+	require.NoError(t, db.Put(&Car{Id: 1, Name: "tesla"}))
+	car, err := db.AuditByKey(&Car{Id: 1})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Compact(WithCompactCoder(JsonCoder{}, &Person{})))
+
+	entries, err := db.AuditByKey(&Person{Id: "jason"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Nil(t, entries[0].Prev)
+	assert.JSONEq(t, `{"Id":"jason","Name":"Jason","Age":0}`, string(entries[0].New))
+	assert.Equal(t, "admin", entries[0].Actor)
+	assert.Equal(t, entries[0].New, entries[1].Prev)
+	assert.JSONEq(t, `{"Id":"jason","Name":"Jason Song","Age":0}`, string(entries[1].New))
+	got, err := db.AuditByKey(&Car{Id: 1})
+	require.NoError(t, err)
+	assert.Equal(t, car, got)
+}
+
+func TestDB_Compact_Concurrent(t *testing.T) {
+	db := fragmentedDB(t)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var written []string
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				id := fmt.Sprintf("writer%d-%02d", i, j)
+				if assert.NoError(t, db.Put(&Person{Id: id})) {
+					mu.Lock()
+					written = append(written, id)
+					mu.Unlock()
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, db.Compact())
+	}
+	wg.Wait()
+
+	require.Len(t, written, 200)
+	for _, id := range written {
+		assert.NoError(t, db.Get(&Person{Id: id}), id)
+	}
+}
+
+func TestDB_Compact_ReopenFailure(t *testing.T) {
+	var opened atomic.Int32
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithOpenFile(func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		if opened.Add(1) > 1 {
+			return nil, errors.New("disk gone")
+		}
+		return os.OpenFile(name, flag, perm)
+	}))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Put(&Person{Id: "jason"}))
+
+	err = db.Compact()
+	assert.ErrorContains(t, err, "unusable")
+	assert.ErrorContains(t, err, "disk gone")
+	assert.ErrorContains(t, db.Get(&Person{Id: "jason"}), "unusable")
+	assert.ErrorContains(t, db.Put(&Person{Id: "vivia"}), "unusable")
+	assert.ErrorContains(t, db.BatchPut(&Person{Id: "vivia"}), "unusable")
+	assert.ErrorContains(t, db.Compact(), "unusable")
+	assert.NoError(t, db.Close())
+}
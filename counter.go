@@ -0,0 +1,93 @@
+package boltutil
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// Incr adds delta to the counter of the key in the bucket and returns the new value, create bucket if it does not exist.
+// The counter is stored as a fixed-width big-endian int64, and a counter which does not exist is 0.
+// Concurrent increments from many goroutines are coalesced into fewer transactions with bbolt.DB.Batch.
+func (d *DB) Incr(hasBucket HasBucket, key []byte, delta int64) (int64, error) {
+	return d.IncrContext(context.Background(), hasBucket, key, delta)
+}
+
+// IncrContext is like Incr but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) IncrContext(ctx context.Context, hasBucket HasBucket, key []byte, delta int64) (_ int64, err error) {
+	op := d.newOperation(ctx, "incr", hasBucket.BoltBucket(), key)
+	defer op.finish(&err)
+
+	if err := d.checkAuditBucket(hasBucket.BoltBucket()); err != nil {
+		return 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var ret int64
+	if err := d.batch(func(tx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucketIfNotExists(hasBucket.BoltBucket())
+		if err != nil {
+			return err
+		}
+		value, err := decodeCounter(bucket.Get(key))
+		if err != nil {
+			return err
+		}
+		value += delta
+		if err := bucket.Put(key, encodeCounter(value)); err != nil {
+			return err
+		}
+		d.invalidate(tx, hasBucket.BoltBucket(), key)
+		ret = value
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return ret, nil
+}
+
+// GetCounter returns the counter of the key in the bucket, 0 if it does not exist.
+func (d *DB) GetCounter(hasBucket HasBucket, key []byte) (int64, error) {
+	return d.GetCounterContext(context.Background(), hasBucket, key)
+}
+
+// GetCounterContext is like GetCounter but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) GetCounterContext(ctx context.Context, hasBucket HasBucket, key []byte) (_ int64, err error) {
+	op := d.newOperation(ctx, "get counter", hasBucket.BoltBucket(), key)
+	defer op.finish(&err)
+
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return 0, err
+	}
+	defer rollback(tx)
+
+	bucket := tx.Bucket(hasBucket.BoltBucket())
+	if bucket == nil {
+		return 0, nil
+	}
+	return decodeCounter(bucket.Get(key))
+}
+
+func encodeCounter(v int64) []byte {
+	ret := make([]byte, 8)
+	binary.BigEndian.PutUint64(ret, uint64(v))
+	return ret
+}
+
+func decodeCounter(value []byte) (int64, error) {
+	if value == nil {
+		return 0, nil
+	}
+	if len(value) != 8 {
+		return 0, fmt.Errorf("invalid counter length: %d", len(value))
+	}
+	return int64(binary.BigEndian.Uint64(value)), nil
+}
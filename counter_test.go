@@ -0,0 +1,65 @@
+package boltutil
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Incr(t *testing.T) {
+	t.Run("regular", func(t *testing.T) {
+		db := testDB(t, true)
+		defer db.Close()
+
+		got, err := db.GetCounter(&Person{}, []byte("2023-01-01"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), got)
+
+		got, err = db.Incr(&Person{}, []byte("2023-01-01"), 2)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), got)
+
+		got, err = db.Incr(&Person{}, []byte("2023-01-01"), -5)
+		require.NoError(t, err)
+		assert.Equal(t, int64(-3), got)
+
+		got, err = db.GetCounter(&Person{}, []byte("2023-01-01"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(-3), got)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		db := testDB(t, true)
+		defer db.Close()
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					if _, err := db.Incr(&Person{}, []byte("counter"), 1); err != nil {
+						t.Error(err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		got, err := db.GetCounter(&Person{}, []byte("counter"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(500), got)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		db := testDB(t)
+		defer db.Close()
+
+		_, err := db.Incr(&Person{}, []byte("jason"), 1)
+		assert.Error(t, err)
+		_, err = db.GetCounter(&Person{}, []byte("jason"))
+		assert.Error(t, err)
+	})
+}
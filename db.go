@@ -2,22 +2,39 @@ package boltutil
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
 	"reflect"
+	"sync"
+	"time"
 
 	"go.etcd.io/bbolt"
 )
 
 type DB struct {
-	db           *bbolt.DB
-	defaultCoder Coder
+	db                *bbolt.DB
+	fileMode          os.FileMode
+	options           *bbolt.Options
+	defaultCoder      Coder
+	defaultKeyDecoder KeyDecoder
+	observer          Observer
+	logger            *slog.Logger
+	slowThreshold     time.Duration
+	cache             *cache
+	auditBucket       []byte
+	mu                sync.RWMutex // held by every operation, and exclusively by Compact which swaps db
+	broken            error        // set if db can not be reopened by Compact, then every operation fails with it
 }
 
 // Open creates and opens a database with given options.
 func Open(path string, options ...Option) (*DB, error) {
 	option := &innerOption{
-		FileMode:     0600,
-		DefaultCoder: GobCoder{},
+		FileMode:          0600,
+		DefaultCoder:      GobCoder{},
+		DefaultKeyDecoder: BinaryKeyDecoder{},
 		Options: func() *bbolt.Options {
 			v := *bbolt.DefaultOptions
 			return &v
@@ -31,10 +48,24 @@ func Open(path string, options ...Option) (*DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	if option.MaxBatchSize > 0 {
+		db.MaxBatchSize = option.MaxBatchSize
+	}
+	if option.MaxBatchDelay > 0 {
+		db.MaxBatchDelay = option.MaxBatchDelay
+	}
 
 	return &DB{
-		db:           db,
-		defaultCoder: option.DefaultCoder,
+		db:                db,
+		fileMode:          option.FileMode,
+		options:           option.Options,
+		defaultCoder:      option.DefaultCoder,
+		defaultKeyDecoder: option.DefaultKeyDecoder,
+		observer:          option.Observer,
+		logger:            option.Logger,
+		slowThreshold:     option.SlowThreshold,
+		cache:             newCache(option.CacheMaxEntries, option.CacheMaxBytes),
+		auditBucket:       option.AuditBucket,
 	}, nil
 }
 
@@ -47,103 +78,78 @@ func Wrap(db *bbolt.DB) *DB {
 
 // Unwrap return the original bbolt.DB
 func (d *DB) Unwrap() *bbolt.DB {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	return d.db
 }
 
 // Close closes the database.
 func (d *DB) Close() error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.broken != nil {
+		return nil
+	}
 	return d.db.Close()
 }
 
 // Get injects storable object with its key.
 func (d *DB) Get(obj Storable, conditions ...*Condition) error {
+	return d.GetContext(context.Background(), obj, conditions...)
+}
+
+// GetContext is like Get but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) GetContext(ctx context.Context, obj Storable, conditions ...*Condition) (err error) {
+	op := d.newOperation(ctx, "get", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
 	var condition *Condition
 	if len(conditions) == 1 {
 		condition = conditions[0]
 	} else if len(conditions) > 1 {
-		return fmt.Errorf("too many conditions")
+		return ErrTooManyConditions
 	}
 
-	tx, err := d.db.Begin(false)
-	if err != nil {
+	if ok, err := d.load(obj); ok {
 		return err
 	}
-	defer rollback(tx)
+	generation := d.getGeneration()
 
-	bucket := tx.Bucket(obj.BoltBucket())
-	if bucket == nil {
-		if condition.getIgnoreIfNotExist() {
-			return nil
-		}
-		return ErrNotExist
-	}
-	got := bucket.Get(obj.BoltKey())
-	if got == nil {
-		if condition.getIgnoreIfNotExist() {
-			return nil
-		}
-		return ErrNotExist
-	}
-	if err := d.getCoder(obj).Decode(bytes.NewReader(got), obj); err != nil {
-		return fmt.Errorf("decode %T %q: %w", obj, obj.BoltKey(), err)
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return err
 	}
+	defer rollback(tx)
 
-	return nil
+	return d.get(op, boltTx{tx}, obj, condition, generation)
 }
 
 // Put stores storable object.
 func (d *DB) Put(obj Storable, conditions ...*Condition) error {
+	return d.PutContext(context.Background(), obj, conditions...)
+}
+
+// PutContext is like Put but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) PutContext(ctx context.Context, obj Storable, conditions ...*Condition) (err error) {
+	op := d.newOperation(ctx, "put", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
 	var condition *Condition
 	if len(conditions) == 1 {
 		condition = conditions[0]
 	} else if len(conditions) > 1 {
-		return fmt.Errorf("too many conditions")
+		return ErrTooManyConditions
 	}
 
-	tx, err := d.db.Begin(true)
+	tx, err := d.begin(ctx, true)
 	if err != nil {
 		return err
 	}
 	defer rollback(tx)
 
-	bucket := tx.Bucket(obj.BoltBucket())
-	if bucket == nil {
-		if condition.getFailIfNotExist() {
-			return ErrNotExist
-		}
-		if bucket, err = tx.CreateBucketIfNotExists(obj.BoltBucket()); err != nil {
-			return err
-		}
-	}
-
-	if condition.getIgnoreIfExist() || condition.getFailIfExist() || condition.getFailIfNotExist() {
-		got := bucket.Get(obj.BoltKey())
-		if got != nil {
-			if condition.getIgnoreIfExist() {
-				return nil
-			}
-			if condition.getFailIfExist() {
-				return ErrAlreadyExist
-			}
-		} else if condition.getFailIfNotExist() {
-			return ErrNotExist
-		}
-	}
-
-	if v, ok := obj.(HasBeforePut); ok {
-		id, err := bucket.NextSequence()
-		if err != nil {
-			return err
-		}
-		v.BeforePut(id)
-	}
-
-	buffer := &bytes.Buffer{}
-	if err := d.getCoder(obj).Encode(buffer, obj); err != nil {
-		return fmt.Errorf("encode %T %q: %w", obj, obj.BoltKey(), err)
-	}
-
-	if err := bucket.Put(obj.BoltKey(), buffer.Bytes()); err != nil {
+	if err := d.putWithCondition(op, boltTx{tx}, obj, condition); err != nil {
 		return err
 	}
 
@@ -152,90 +158,166 @@ func (d *DB) Put(obj Storable, conditions ...*Condition) error {
 
 // Delete deletes storable object.
 func (d *DB) Delete(obj Storable, conditions ...*Condition) error {
+	return d.DeleteContext(context.Background(), obj, conditions...)
+}
+
+// DeleteContext is like Delete but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) DeleteContext(ctx context.Context, obj Storable, conditions ...*Condition) (err error) {
+	op := d.newOperation(ctx, "delete", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
 	var condition *Condition
 	if len(conditions) == 1 {
 		condition = conditions[0]
 	} else if len(conditions) > 1 {
-		return fmt.Errorf("too many conditions")
+		return ErrTooManyConditions
 	}
 
-	tx, err := d.db.Begin(true)
+	tx, err := d.begin(ctx, true)
 	if err != nil {
 		return err
 	}
 	defer rollback(tx)
 
-	bucket := tx.Bucket(obj.BoltBucket())
-	if bucket == nil {
-		if condition.getFailIfNotExist() {
-			return ErrNotExist
-		}
-		return nil
-	}
-	if err := bucket.Delete(obj.BoltKey()); err != nil {
+	if err := d.deleteWithCondition(op, boltTx{tx}, obj, condition); err != nil {
 		return err
 	}
 	return tx.Commit()
 }
 
+// GetAndDelete injects storable object with its key and deletes it, fails if it does not exist.
+func (d *DB) GetAndDelete(obj Storable) error {
+	return d.GetAndDeleteContext(context.Background(), obj)
+}
+
+// GetAndDeleteContext is like GetAndDelete but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) GetAndDeleteContext(ctx context.Context, obj Storable) error {
+	return d.DeleteContext(ctx, obj, NewCondition().FailIfNotExist().LoadDeleted())
+}
+
 // MGet injects storable objects with their keys.
 func (d *DB) MGet(objs ...Storable) error {
-	tx, err := d.db.Begin(false)
+	return d.MGetContext(context.Background(), objs...)
+}
+
+// MGetContext is like MGet but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) MGetContext(ctx context.Context, objs ...Storable) (err error) {
+	op := d.newOperation(ctx, "mget", nil, nil)
+	defer op.finish(&err)
+
+	generation := d.getGeneration()
+	tx, err := d.begin(ctx, false)
 	if err != nil {
 		return err
 	}
 	defer rollback(tx)
 
-	for _, obj := range objs {
-		bucket := tx.Bucket(obj.BoltBucket())
-		if bucket == nil {
-			return ErrNotExist
-		}
-		got := bucket.Get(obj.BoltKey())
-		if got == nil {
-			return ErrNotExist
+	return d.mget(op, boltTx{tx}, objs, generation)
+}
+
+// MGetResult is the outcome of every object of MGetPartial, in the same order of the objects.
+type MGetResult struct {
+	Found []bool  // whether the object is found and injected
+	Errs  []error // error of the object, nil if it is found or ignored with IgnoreIfNotExist
+}
+
+// Err returns the errors of the objects joined, nil if there is none.
+func (r *MGetResult) Err() error {
+	return errors.Join(r.Errs...)
+}
+
+// MGetPartial injects storable objects with their keys as MGet, but it does not abort on the first failure,
+// every found object is injected and the outcomes are reported in the result.
+// With IgnoreIfNotExist, objects which do not exist are reported as not found without errors.
+func (d *DB) MGetPartial(objs []Storable, conditions ...*Condition) (*MGetResult, error) {
+	return d.MGetPartialContext(context.Background(), objs, conditions...)
+}
+
+// MGetPartialContext is like MGetPartial but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) MGetPartialContext(ctx context.Context, objs []Storable, conditions ...*Condition) (_ *MGetResult, err error) {
+	op := d.newOperation(ctx, "mget", nil, nil)
+	defer op.finish(&err)
+
+	var condition *Condition
+	if len(conditions) == 1 {
+		condition = conditions[0]
+	} else if len(conditions) > 1 {
+		return nil, ErrTooManyConditions
+	}
+
+	generation := d.getGeneration()
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	result := &MGetResult{
+		Found: make([]bool, len(objs)),
+		Errs:  make([]error, len(objs)),
+	}
+	for i, obj := range objs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-		if err := d.getCoder(obj).Decode(bytes.NewReader(got), obj); err != nil {
-			return fmt.Errorf("decode %T %q: %w", obj, obj.BoltKey(), err)
+
+		op.addKey(obj.BoltKey())
+		var err error
+		if ok, loadErr := d.load(obj); ok {
+			if err = loadErr; err == nil {
+				result.Found[i] = true
+			}
+		} else if bucket := tx.Bucket(obj.BoltBucket()); bucket == nil {
+			if !condition.getIgnoreIfNotExist() {
+				err = newOpError(obj, ErrBucketNotExist)
+			}
+		} else if got := bucket.Get(obj.BoltKey()); got == nil {
+			if !condition.getIgnoreIfNotExist() {
+				err = newOpError(obj, ErrKeyNotExist)
+			}
+		} else if err = d.decodeCached(op, got, obj, generation); err == nil {
+			result.Found[i] = true
 		}
+		wrapError(&err, "mget", obj.BoltBucket(), obj.BoltKey())
+		result.Errs[i] = err
 	}
 
-	return nil
+	return result, nil
 }
 
 // MPut store storables into database, create bucket if it does not exist.
 func (d *DB) MPut(objs ...Storable) error {
-	tx, err := d.db.Begin(true)
+	return d.MPutWithConditionContext(context.Background(), nil, objs...)
+}
+
+// MPutContext is like MPut but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) MPutContext(ctx context.Context, objs ...Storable) error {
+	return d.MPutWithConditionContext(ctx, nil, objs...)
+}
+
+// MPutWithCondition store storables into database with the condition applied to every object as Put,
+// all or nothing is stored, the error identifies the object violating the condition.
+func (d *DB) MPutWithCondition(condition *Condition, objs ...Storable) error {
+	return d.MPutWithConditionContext(context.Background(), condition, objs...)
+}
+
+// MPutWithConditionContext is like MPutWithCondition but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) MPutWithConditionContext(ctx context.Context, condition *Condition, objs ...Storable) (err error) {
+	op := d.newOperation(ctx, "mput", nil, nil)
+	defer op.finish(&err)
+
+	tx, err := d.begin(ctx, true)
 	if err != nil {
 		return err
 	}
 	defer rollback(tx)
 
-	buffer := &bytes.Buffer{}
 	for _, obj := range objs {
-		bucket := tx.Bucket(obj.BoltBucket())
-		if bucket == nil {
-			if bucket, err = tx.CreateBucketIfNotExists(obj.BoltBucket()); err != nil {
-				return err
-			}
-		}
-
-		if v, ok := obj.(HasBeforePut); ok {
-			id, err := bucket.NextSequence()
-			if err != nil {
-				return err
-			}
-			v.BeforePut(id)
-		}
-
-		buffer.Reset()
-		if err := d.getCoder(obj).Encode(buffer, obj); err != nil {
-			return fmt.Errorf("encode %T %q: %w", obj, obj.BoltKey(), err)
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		value := make([]byte, buffer.Len())
-		copy(value, buffer.Bytes())
-		if err := bucket.Put(obj.BoltKey(), value); err != nil {
+		if err := d.putWithCondition(op, boltTx{tx}, obj, condition); err != nil {
 			return err
 		}
 	}
@@ -245,18 +327,37 @@ func (d *DB) MPut(objs ...Storable) error {
 
 // MDelete remove values by key of storables
 func (d *DB) MDelete(objs ...Storable) error {
-	tx, err := d.db.Begin(true)
+	return d.MDeleteWithConditionContext(context.Background(), nil, objs...)
+}
+
+// MDeleteContext is like MDelete but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) MDeleteContext(ctx context.Context, objs ...Storable) error {
+	return d.MDeleteWithConditionContext(ctx, nil, objs...)
+}
+
+// MDeleteWithCondition remove values by key of storables with the condition applied to every object as Delete,
+// all or nothing is removed, the error identifies the object violating the condition.
+func (d *DB) MDeleteWithCondition(condition *Condition, objs ...Storable) error {
+	return d.MDeleteWithConditionContext(context.Background(), condition, objs...)
+}
+
+// MDeleteWithConditionContext is like MDeleteWithCondition but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) MDeleteWithConditionContext(ctx context.Context, condition *Condition, objs ...Storable) (err error) {
+	op := d.newOperation(ctx, "mdelete", nil, nil)
+	defer op.finish(&err)
+
+	tx, err := d.begin(ctx, true)
 	if err != nil {
 		return err
 	}
 	defer rollback(tx)
 
 	for _, obj := range objs {
-		bucket := tx.Bucket(obj.BoltBucket())
-		if bucket == nil {
-			continue
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-		if err := bucket.Delete(obj.BoltKey()); err != nil {
+
+		if err := d.deleteWithCondition(op, boltTx{tx}, obj, condition); err != nil {
 			return err
 		}
 	}
@@ -265,27 +366,73 @@ func (d *DB) MDelete(objs ...Storable) error {
 
 // Scan scans values in the bucket and put them into result.
 func (d *DB) Scan(result any, filters ...*Filter) error {
+	return d.ScanContext(context.Background(), result, filters...)
+}
+
+// ScanContext is like Scan but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) ScanContext(ctx context.Context, result any, filters ...*Filter) (err error) {
+	op := d.newOperation(ctx, "scan", nil, nil)
+	defer op.finish(&err)
+
+	var filter *Filter
+	if len(filters) == 1 {
+		filter = filters[0]
+	} else if len(filters) > 1 {
+		return ErrTooManyFilters
+	}
+
+	slice, itemType, bucketName, err := scanSlice(result)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	bucket := tx.Bucket(bucketName)
+	if bucket == nil {
+		return nil
+	}
+
+	return d.scan(op, bucket.Cursor(), slice, itemType, filter)
+}
+
+// ScanMap scans values in the bucket and put them into result, which should be a pointer to map[K]*T.
+// The map keys are decoded from the bolt keys with the KeyDecoder of T, or the default one of the DB.
+func (d *DB) ScanMap(result any, filters ...*Filter) error {
+	return d.ScanMapContext(context.Background(), result, filters...)
+}
+
+// ScanMapContext is like ScanMap but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) ScanMapContext(ctx context.Context, result any, filters ...*Filter) (err error) {
+	op := d.newOperation(ctx, "scan", nil, nil)
+	defer op.finish(&err)
+
 	var filter *Filter
 	if len(filters) == 1 {
 		filter = filters[0]
 	} else if len(filters) > 1 {
-		return fmt.Errorf("too many filters")
+		return ErrTooManyFilters
 	}
 
 	if reflect.TypeOf(result).Kind() != reflect.Ptr {
-		return fmt.Errorf("should be slice pointer: %T", result)
+		return fmt.Errorf("should be map pointer: %T", result)
 	}
 
-	slice := reflect.ValueOf(result).Elem()
-	if slice.Kind() != reflect.Slice {
-		return fmt.Errorf("should be slice pointer: %T", result)
+	m := reflect.ValueOf(result).Elem()
+	if m.Kind() != reflect.Map {
+		return fmt.Errorf("should be map pointer: %T", result)
 	}
 
-	if slice.Len() != 0 {
-		return fmt.Errorf("should be empty: len %d", slice.Len())
+	if m.Len() != 0 {
+		return fmt.Errorf("should be empty: len %d", m.Len())
 	}
 
-	itemType := slice.Type().Elem()
+	keyType := m.Type().Key()
+	itemType := m.Type().Elem()
 	if itemType.Kind() != reflect.Ptr {
 		return fmt.Errorf("item should be pointer: %v", itemType)
 	}
@@ -293,78 +440,83 @@ func (d *DB) Scan(result any, filters ...*Filter) error {
 	item := reflect.New(itemType).Interface()
 
 	var bucketName []byte
-	var coder Coder
 	if obj, ok := item.(Storable); ok {
 		bucketName = obj.BoltBucket()
-		coder = d.getCoder(obj)
 	} else {
 		return fmt.Errorf("item should implement Storable: %T", item)
 	}
+	keyDecoder := d.getKeyDecoder(item)
 
-	tx, err := d.db.Begin(false)
+	tx, err := d.begin(ctx, false)
 	if err != nil {
 		return err
 	}
 	defer rollback(tx)
 
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+
 	bucket := tx.Bucket(bucketName)
 	if bucket == nil {
 		return nil
 	}
 
-	cur := bucket.Cursor()
-	if seek := filter.seek(); seek != nil {
-		cur.Seek(seek)
+	newObj := func() Storable {
+		return reflect.New(itemType).Interface().(Storable)
 	}
-SCAN:
-	for k, v := cur.First(); filter.goon(k); k, v = cur.Next() {
-		for _, c := range filter.getConditions() {
-			if c == nil {
-				continue
-			}
-			skip, stop := c(k, v)
-			if stop {
-				break SCAN
-			}
-			if skip {
-				continue SCAN
+	setItem := func(k []byte, obj Storable) error {
+		key := reflect.New(keyType)
+		if err := keyDecoder.DecodeKey(k, key.Interface()); err != nil {
+			return &OpError{
+				Bucket: bucketName,
+				Key:    bytes.Clone(k),
+				Err:    newCodecError("decode key into", reflect.Zero(keyType).Interface(), err),
 			}
 		}
+		m.SetMapIndex(key.Elem(), reflect.ValueOf(obj))
+		return nil
+	}
 
-		obj := reflect.New(itemType).Interface()
-		if err := coder.Decode(bytes.NewReader(v), obj); err != nil {
-			return fmt.Errorf("decode %T %q: %w", obj, k, err)
+	if less := filter.getLess(); less != nil {
+		sorter := newSorter(less, filter.getLimit())
+		if err := d.iterate(op, bucket.Cursor(), filter, newObj, true, func(k, v []byte, obj Storable) (bool, error) {
+			sorter.Push(obj)
+			return false, nil
+		}); err != nil {
+			return err
 		}
-
-		for _, c := range filter.getStorableConditions() {
-			if c == nil {
-				continue
-			}
-			skip, stop := c(obj.(Storable))
-			if stop {
-				break SCAN
-			}
-			if skip {
-				continue SCAN
+		for _, obj := range sorter.Result() {
+			if err := setItem(obj.BoltKey(), obj); err != nil {
+				return err
 			}
 		}
-
-		slice.Set(reflect.Append(slice, reflect.ValueOf(obj)))
+		return nil
 	}
 
-	return nil
+	return d.iterate(op, bucket.Cursor(), filter, newObj, true, func(k, v []byte, obj Storable) (bool, error) {
+		return false, setItem(k, obj)
+	})
 }
 
 // First injects the first value in the bucket into result.
 func (d *DB) First(obj Storable, filters ...*Filter) error {
+	return d.FirstContext(context.Background(), obj, filters...)
+}
+
+// FirstContext is like First but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) FirstContext(ctx context.Context, obj Storable, filters ...*Filter) (err error) {
+	op := d.newOperation(ctx, "first", obj.BoltBucket(), nil)
+	defer op.finish(&err)
+
 	var filter *Filter
 	if len(filters) == 1 {
 		filter = filters[0]
 	} else if len(filters) > 1 {
-		return fmt.Errorf("too many filters")
+		return ErrTooManyFilters
 	}
 
-	tx, err := d.db.Begin(false)
+	tx, err := d.begin(ctx, false)
 	if err != nil {
 		return err
 	}
@@ -375,54 +527,27 @@ func (d *DB) First(obj Storable, filters ...*Filter) error {
 		return nil
 	}
 
-	cur := bucket.Cursor()
-	if seek := filter.seek(); seek != nil {
-		cur.Seek(seek)
-	}
-SCAN:
-	for k, v := cur.First(); filter.goon(k); k, v = cur.Next() {
-		for _, c := range filter.getConditions() {
-			if c == nil {
-				continue
-			}
-			skip, stop := c(k, v)
-			if stop {
-				break SCAN
-			}
-			if skip {
-				continue SCAN
-			}
-		}
-		if err := d.getCoder(obj).Decode(bytes.NewReader(v), obj); err != nil {
-			return fmt.Errorf("decode %T %q: %w", obj, k, err)
-		}
-		for _, c := range filter.getStorableConditions() {
-			if c == nil {
-				continue
-			}
-			skip, stop := c(obj)
-			if stop {
-				break SCAN
-			}
-			if skip {
-				continue SCAN
-			}
-		}
-		return nil
-	}
-	return ErrNotExist
+	return d.first(op, bucket.Cursor(), obj, filter)
 }
 
 // Count return count of kv in the bucket.
 func (d *DB) Count(obj Storable, filters ...*Filter) (int, error) {
+	return d.CountContext(context.Background(), obj, filters...)
+}
+
+// CountContext is like Count but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) CountContext(ctx context.Context, obj Storable, filters ...*Filter) (_ int, err error) {
+	op := d.newOperation(ctx, "count", obj.BoltBucket(), nil)
+	defer op.finish(&err)
+
 	var filter *Filter
 	if len(filters) == 1 {
 		filter = filters[0]
 	} else if len(filters) > 1 {
-		return 0, fmt.Errorf("too many filters")
+		return 0, ErrTooManyFilters
 	}
 
-	tx, err := d.db.Begin(false)
+	tx, err := d.begin(ctx, false)
 	if err != nil {
 		return 0, err
 	}
@@ -433,52 +558,22 @@ func (d *DB) Count(obj Storable, filters ...*Filter) (int, error) {
 		return 0, nil
 	}
 
-	count := 0
-	cur := bucket.Cursor()
-	if seek := filter.seek(); seek != nil {
-		cur.Seek(seek)
-	}
-SCAN:
-	for k, v := cur.First(); filter.goon(k); k, v = cur.Next() {
-		for _, c := range filter.getConditions() {
-			if c == nil {
-				continue
-			}
-			skip, stop := c(k, v)
-			if stop {
-				break SCAN
-			}
-			if skip {
-				continue SCAN
-			}
-		}
-		if len(filter.getStorableConditions()) > 0 {
-			if err := d.getCoder(obj).Decode(bytes.NewReader(v), obj); err != nil {
-				return 0, fmt.Errorf("decode %T %q: %w", obj, k, err)
-			}
-			for _, c := range filter.getStorableConditions() {
-				if c == nil {
-					continue
-				}
-				skip, stop := c(obj)
-				if stop {
-					break SCAN
-				}
-				if skip {
-					continue SCAN
-				}
-			}
-		}
-		count++
-	}
-	return count, nil
-}
-
-// Exist check if the storable exist
-func (d *DB) Exist(obj Storable) (bool, error) {
-	tx, err := d.db.Begin(false)
-	if err != nil {
-		return false, err
+	return d.count(op, bucket.Cursor(), obj, filter)
+}
+
+// Exist check if the storable exist
+func (d *DB) Exist(obj Storable) (bool, error) {
+	return d.ExistContext(context.Background(), obj)
+}
+
+// ExistContext is like Exist but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) ExistContext(ctx context.Context, obj Storable) (_ bool, err error) {
+	op := d.newOperation(ctx, "exist", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return false, err
 	}
 	defer rollback(tx)
 
@@ -490,15 +585,89 @@ func (d *DB) Exist(obj Storable) (bool, error) {
 	return got != nil, nil
 }
 
+// NextSequence returns an auto incrementing integer for the bucket, create bucket if it does not exist.
+func (d *DB) NextSequence(hasBucket HasBucket) (uint64, error) {
+	return d.NextSequenceContext(context.Background(), hasBucket)
+}
+
+// NextSequenceContext is like NextSequence but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) NextSequenceContext(ctx context.Context, hasBucket HasBucket) (_ uint64, err error) {
+	op := d.newOperation(ctx, "next sequence", hasBucket.BoltBucket(), nil)
+	defer op.finish(&err)
+
+	if err := d.checkAuditBucket(hasBucket.BoltBucket()); err != nil {
+		return 0, err
+	}
+	tx, err := d.begin(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	defer rollback(tx)
+
+	bucket, err := tx.CreateBucketIfNotExists(hasBucket.BoltBucket())
+	if err != nil {
+		return 0, err
+	}
+	id, err := bucket.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// SetSequence updates the sequence number for the bucket, create bucket if it does not exist.
+func (d *DB) SetSequence(hasBucket HasBucket, v uint64) error {
+	return d.SetSequenceContext(context.Background(), hasBucket, v)
+}
+
+// SetSequenceContext is like SetSequence but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) SetSequenceContext(ctx context.Context, hasBucket HasBucket, v uint64) (err error) {
+	op := d.newOperation(ctx, "set sequence", hasBucket.BoltBucket(), nil)
+	defer op.finish(&err)
+
+	if err := d.checkAuditBucket(hasBucket.BoltBucket()); err != nil {
+		return err
+	}
+	tx, err := d.begin(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	bucket, err := tx.CreateBucketIfNotExists(hasBucket.BoltBucket())
+	if err != nil {
+		return err
+	}
+	if err := bucket.SetSequence(v); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // DeleteBucket remove the specified buckets
 func (d *DB) DeleteBucket(hasBuckets ...HasBucket) error {
-	tx, err := d.db.Begin(true)
+	return d.DeleteBucketContext(context.Background(), hasBuckets...)
+}
+
+// DeleteBucketContext is like DeleteBucket but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) DeleteBucketContext(ctx context.Context, hasBuckets ...HasBucket) (err error) {
+	op := d.newOperation(ctx, "delete bucket", nil, nil)
+	defer op.finish(&err)
+
+	tx, err := d.begin(ctx, true)
 	if err != nil {
 		return err
 	}
 	defer rollback(tx)
 
 	for _, obj := range hasBuckets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := d.checkAuditBucket(obj.BoltBucket()); err != nil {
+			return err
+		}
 		bucket := tx.Bucket(obj.BoltBucket())
 		if bucket == nil {
 			continue
@@ -506,13 +675,22 @@ func (d *DB) DeleteBucket(hasBuckets ...HasBucket) error {
 		if err := tx.DeleteBucket(obj.BoltBucket()); err != nil {
 			return nil
 		}
+		d.invalidateBucket(tx, obj.BoltBucket())
 	}
 	return tx.Commit()
 }
 
 // DeleteAllBucket remove all buckets
 func (d *DB) DeleteAllBucket() error {
-	tx, err := d.db.Begin(true)
+	return d.DeleteAllBucketContext(context.Background())
+}
+
+// DeleteAllBucketContext is like DeleteAllBucket but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) DeleteAllBucketContext(ctx context.Context) (err error) {
+	op := d.newOperation(ctx, "delete all bucket", nil, nil)
+	defer op.finish(&err)
+
+	tx, err := d.begin(ctx, true)
 	if err != nil {
 		return err
 	}
@@ -520,17 +698,531 @@ func (d *DB) DeleteAllBucket() error {
 
 	var buckets [][]byte
 	if err := tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
-		buckets = append(buckets, name)
+		if !d.isAuditBucket(name) {
+			buckets = append(buckets, name)
+		}
 		return nil
 	}); err != nil {
 		return err
 	}
 
 	for _, bucket := range buckets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if err := tx.DeleteBucket(bucket); err != nil {
 			return nil
 		}
 	}
+	d.invalidateBucket(tx, nil)
+	return tx.Commit()
+}
+
+// get injects obj from its bucket in tx.
+func (d *DB) get(op *operation, tx kvTx, obj Storable, condition *Condition, generation uint64) error {
+	bucket := tx.bucket(obj.BoltBucket())
+	if bucket == nil {
+		if condition.getIgnoreIfNotExist() {
+			return nil
+		}
+		return ErrBucketNotExist
+	}
+	got := bucket.Get(obj.BoltKey())
+	if got == nil {
+		if condition.getIgnoreIfNotExist() {
+			return nil
+		}
+		return ErrKeyNotExist
+	}
+	return d.decodeCached(op, got, obj, generation)
+}
+
+// mget injects objs from their buckets in tx, it aborts on the first failure.
+func (d *DB) mget(op *operation, tx kvTx, objs []Storable, generation uint64) error {
+	for _, obj := range objs {
+		if err := op.ctx.Err(); err != nil {
+			return err
+		}
+
+		op.addKey(obj.BoltKey())
+		if ok, err := d.load(obj); ok {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		bucket := tx.bucket(obj.BoltBucket())
+		if bucket == nil {
+			return newOpError(obj, ErrBucketNotExist)
+		}
+		got := bucket.Get(obj.BoltKey())
+		if got == nil {
+			return newOpError(obj, ErrKeyNotExist)
+		}
+		if err := d.decodeCached(op, got, obj, generation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanSlice checks result of Scan, returns the slice, the type of items and the bucket of it.
+func scanSlice(result any) (reflect.Value, reflect.Type, []byte, error) {
+	if reflect.TypeOf(result).Kind() != reflect.Ptr {
+		return reflect.Value{}, nil, nil, fmt.Errorf("should be slice pointer: %T", result)
+	}
+
+	slice := reflect.ValueOf(result).Elem()
+	if slice.Kind() != reflect.Slice {
+		return reflect.Value{}, nil, nil, fmt.Errorf("should be slice pointer: %T", result)
+	}
+
+	if slice.Len() != 0 {
+		return reflect.Value{}, nil, nil, fmt.Errorf("should be empty: len %d", slice.Len())
+	}
+
+	itemType := slice.Type().Elem()
+	if itemType.Kind() != reflect.Ptr {
+		return reflect.Value{}, nil, nil, fmt.Errorf("item should be pointer: %v", itemType)
+	}
+	itemType = itemType.Elem()
+	item := reflect.New(itemType).Interface()
+
+	obj, ok := item.(Storable)
+	if !ok {
+		return reflect.Value{}, nil, nil, fmt.Errorf("item should implement Storable: %T", item)
+	}
+	return slice, itemType, obj.BoltBucket(), nil
+}
+
+// scan appends the records of the cursor which match the filter to slice.
+func (d *DB) scan(op *operation, cur cursor, slice reflect.Value, itemType reflect.Type, filter *Filter) error {
+	newObj := func() Storable {
+		return reflect.New(itemType).Interface().(Storable)
+	}
+
+	if less := filter.getLess(); less != nil {
+		sorter := newSorter(less, filter.getLimit())
+		if err := d.iterate(op, cur, filter, newObj, true, func(k, v []byte, obj Storable) (bool, error) {
+			sorter.Push(obj)
+			return false, nil
+		}); err != nil {
+			return err
+		}
+		for _, obj := range sorter.Result() {
+			slice.Set(reflect.Append(slice, reflect.ValueOf(obj)))
+		}
+		return nil
+	}
+
+	return d.iterate(op, cur, filter, newObj, true, func(k, v []byte, obj Storable) (bool, error) {
+		slice.Set(reflect.Append(slice, reflect.ValueOf(obj)))
+		return false, nil
+	})
+}
+
+// first injects the first record of the cursor which matches the filter into obj.
+func (d *DB) first(op *operation, cur cursor, obj Storable, filter *Filter) error {
+	if less := filter.getLess(); less != nil {
+		itemType := reflect.TypeOf(obj).Elem()
+		sorter := newSorter(less, 1)
+		if err := d.iterate(op, cur, filter, func() Storable {
+			return reflect.New(itemType).Interface().(Storable)
+		}, true, func(k, v []byte, obj Storable) (bool, error) {
+			sorter.Push(obj)
+			return false, nil
+		}); err != nil {
+			return err
+		}
+		result := sorter.Result()
+		if len(result) == 0 {
+			return ErrKeyNotExist
+		}
+		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(result[0]).Elem())
+		return nil
+	}
+
+	// decode into new objects if bad records are skipped, so obj is not polluted by them
+	newObj := func() Storable {
+		return obj
+	}
+	if filter.getSkipBadRecords() {
+		itemType := reflect.TypeOf(obj).Elem()
+		newObj = func() Storable {
+			return reflect.New(itemType).Interface().(Storable)
+		}
+	}
+	found := false
+	if err := d.iterate(op, cur, filter, newObj, true, func(k, v []byte, got Storable) (bool, error) {
+		if got != obj {
+			reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(got).Elem())
+		}
+		found = true
+		return true, nil
+	}); err != nil {
+		return err
+	}
+	if !found {
+		return ErrKeyNotExist
+	}
+	return nil
+}
+
+// count returns the count of the records of the cursor which match the filter.
+func (d *DB) count(op *operation, cur cursor, obj Storable, filter *Filter) (int, error) {
+	count := 0
+	// the records are decoded to tell the bad ones if they are skipped
+	if err := d.iterate(op, cur, filter, func() Storable {
+		return obj
+	}, filter.getSkipBadRecords(), func(k, v []byte, obj Storable) (bool, error) {
+		count++
+		return false, nil
+	}); err != nil {
+		return 0, err
+	}
+	if limit := filter.getLimit(); limit > 0 && count > limit {
+		count = limit
+	}
+	return count, nil
+}
+
+// putWithCondition puts obj into its bucket if the condition is satisfied, create bucket if it does not exist.
+func (d *DB) putWithCondition(op *operation, tx kvTx, obj Storable, condition *Condition) error {
+	if err := d.checkAuditBucket(obj.BoltBucket()); err != nil {
+		return err
+	}
+	bucket := tx.bucket(obj.BoltBucket())
+	if bucket == nil {
+		if condition.getFailIfNotExist() {
+			return newOpError(obj, ErrBucketNotExist)
+		}
+		var err error
+		if bucket, err = tx.createBucketIfNotExists(obj.BoltBucket()); err != nil {
+			return newOpError(obj, err)
+		}
+	}
+
+	if condition.getIgnoreIfExist() || condition.getFailIfExist() || condition.getFailIfNotExist() {
+		got := bucket.Get(obj.BoltKey())
+		if got != nil {
+			if condition.getIgnoreIfExist() {
+				return nil
+			}
+			if condition.getFailIfExist() {
+				return newOpError(obj, ErrAlreadyExist)
+			}
+		} else if condition.getFailIfNotExist() {
+			return newOpError(obj, ErrKeyNotExist)
+		}
+	}
+
+	return d.put(op, tx, bucket, obj)
+}
+
+// deleteWithCondition deletes obj from its bucket if the condition is satisfied.
+func (d *DB) deleteWithCondition(op *operation, tx kvTx, obj Storable, condition *Condition) error {
+	if err := d.checkAuditBucket(obj.BoltBucket()); err != nil {
+		return err
+	}
+	bucket := tx.bucket(obj.BoltBucket())
+	if bucket == nil {
+		if condition.getFailIfNotExist() {
+			return newOpError(obj, ErrBucketNotExist)
+		}
+		return nil
+	}
+
+	if condition.getFailIfNotExist() || condition.getLoadDeleted() {
+		got := bucket.Get(obj.BoltKey())
+		if got == nil {
+			if condition.getFailIfNotExist() {
+				return newOpError(obj, ErrKeyNotExist)
+			}
+			return nil
+		}
+		if condition.getLoadDeleted() {
+			if err := d.decode(op, obj.BoltKey(), got, obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	return d.delete(op, tx, bucket, obj.BoltKey(), obj)
+}
+
+// put encodes obj and puts it into the bucket, with the hooks of obj called.
+func (d *DB) put(op *operation, tx kvTx, bucket kvBucket, obj Storable) error {
+	if v, ok := obj.(HasBeforePut); ok {
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		v.BeforePut(id)
+	}
+
+	inserter, isInserter := obj.(HasBeforeInsert)
+	updater, isUpdater := obj.(HasBeforeUpdate)
+	if isInserter || isUpdater {
+		if bucket.Get(obj.BoltKey()) == nil {
+			if isInserter {
+				id, err := bucket.NextSequence()
+				if err != nil {
+					return err
+				}
+				inserter.BeforeInsert(id)
+			}
+		} else if isUpdater {
+			updater.BeforeUpdate()
+		}
+	}
+
+	if v, ok := obj.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return newOpError(obj, fmt.Errorf("validate %T: %w: %w", obj, ErrInvalid, err))
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := d.getCoder(obj).Encode(buffer, obj); err != nil {
+		return newOpError(obj, newCodecError("encode", obj, err))
+	}
+
+	var prev []byte
+	if d.auditBucket != nil {
+		prev = bytes.Clone(bucket.Get(obj.BoltKey()))
+	}
+	if err := bucket.Put(obj.BoltKey(), buffer.Bytes()); err != nil {
+		return newOpError(obj, err)
+	}
+	if err := d.audit(op, tx, obj.BoltBucket(), obj.BoltKey(), prev, buffer.Bytes()); err != nil {
+		return newOpError(obj, err)
+	}
+	d.invalidate(bucket.Tx(), obj.BoltBucket(), obj.BoltKey())
+	op.addKey(obj.BoltKey())
+	op.addBytes(buffer.Len())
+
+	if v, ok := obj.(HasAfterPut); ok {
+		if bucket.Tx() == nil {
+			return newOpError(obj, fmt.Errorf("after put %T: %w", obj, errors.ErrUnsupported))
+		}
+		if err := v.AfterPut(bucket.Tx()); err != nil {
+			return newOpError(obj, fmt.Errorf("after put %T: %w", obj, err))
+		}
+	}
+	return nil
+}
+
+// delete deletes the key from the bucket, calls BeforeDelete of obj first if it implements HasBeforeDelete,
+// and moves the record into the tombstone bucket if obj is soft deleted.
+func (d *DB) delete(op *operation, tx kvTx, bucket kvBucket, key []byte, obj Storable) error {
+	if v, ok := obj.(HasBeforeDelete); ok {
+		if bucket.Tx() == nil {
+			return &OpError{
+				Bucket: obj.BoltBucket(),
+				Key:    bytes.Clone(key),
+				Err:    fmt.Errorf("before delete %T: %w", obj, errors.ErrUnsupported),
+			}
+		}
+		if err := v.BeforeDelete(bucket.Tx()); err != nil {
+			return &OpError{
+				Bucket: obj.BoltBucket(),
+				Key:    bytes.Clone(key),
+				Err:    fmt.Errorf("before delete %T: %w", obj, err),
+			}
+		}
+	}
+	var prev []byte
+	if d.auditBucket != nil {
+		prev = bytes.Clone(bucket.Get(key))
+	}
+	if v, ok := obj.(HasSoftDelete); ok && v.BoltSoftDelete() {
+		if err := tombstone(tx, bucket, obj.BoltBucket(), key); err != nil {
+			return &OpError{
+				Bucket: obj.BoltBucket(),
+				Key:    bytes.Clone(key),
+				Err:    err,
+			}
+		}
+	}
+	if err := bucket.Delete(key); err != nil {
+		return &OpError{
+			Bucket: obj.BoltBucket(),
+			Key:    bytes.Clone(key),
+			Err:    err,
+		}
+	}
+	if prev != nil {
+		if err := d.audit(op, tx, obj.BoltBucket(), key, prev, nil); err != nil {
+			return &OpError{
+				Bucket: obj.BoltBucket(),
+				Key:    bytes.Clone(key),
+				Err:    err,
+			}
+		}
+	}
+	d.invalidate(bucket.Tx(), obj.BoltBucket(), key)
+	op.addKey(key)
+	return nil
+}
+
+// decode decodes value of the key into obj, calls AfterGet of obj then if it implements HasAfterGet.
+func (d *DB) decode(op *operation, key, value []byte, obj Storable) error {
+	op.addBytes(len(value))
+	if err := d.getCoder(obj).Decode(bytes.NewReader(value), obj); err != nil {
+		return &OpError{
+			Bucket: obj.BoltBucket(),
+			Key:    bytes.Clone(key),
+			Err:    newCodecError("decode", obj, err),
+		}
+	}
+	return afterGet(key, obj)
+}
+
+// afterGet calls AfterGet of obj if it implements HasAfterGet.
+func afterGet(key []byte, obj Storable) error {
+	if v, ok := obj.(HasAfterGet); ok {
+		if err := v.AfterGet(); err != nil {
+			return &OpError{
+				Bucket: obj.BoltBucket(),
+				Key:    bytes.Clone(key),
+				Err:    fmt.Errorf("after get %T: %w", obj, err),
+			}
+		}
+	}
+	return nil
+}
+
+// iterate walks through the cursor with the filter and calls fn for every matched record.
+// The value is decoded into an object created by newObj if decode is true or the filter has storable conditions,
+// otherwise obj passed to fn is nil.
+// The limit of the filter is ignored if it is sorted, since the callers have to collect all records to sort.
+func (d *DB) iterate(op *operation, cur cursor, filter *Filter, newObj func() Storable, decode bool,
+	fn func(k, v []byte, obj Storable) (stop bool, err error)) error {
+	decode = decode || len(filter.getStorableConditions()) > 0
+	limit := filter.getLimit()
+	if filter.getLess() != nil {
+		limit = 0
+	}
+	matched := 0
+
+SCAN:
+	for k, v := filter.first(cur); filter.goon(k); k, v = cur.Next() {
+		if err := op.ctx.Err(); err != nil {
+			return err
+		}
+
+		for _, c := range filter.getConditions() {
+			if c == nil {
+				continue
+			}
+			skip, stop := c(k, v)
+			if stop {
+				break SCAN
+			}
+			if skip {
+				continue SCAN
+			}
+		}
+
+		var obj Storable
+		if decode {
+			obj = newObj()
+			if err := d.decode(op, k, v, obj); err != nil {
+				if filter.skipBadRecord(obj.BoltBucket(), k, err) {
+					continue
+				}
+				return err
+			}
+			for _, c := range filter.getStorableConditions() {
+				if c == nil {
+					continue
+				}
+				skip, stop := c(obj)
+				if stop {
+					break SCAN
+				}
+				if skip {
+					continue SCAN
+				}
+			}
+		}
+
+		stop, err := fn(k, v, obj)
+		if err != nil {
+			return err
+		}
+		matched++
+		if stop || (limit > 0 && matched >= limit) {
+			break
+		}
+	}
+	return nil
+}
+
+// newOpError returns an *OpError with the bucket and the key of obj, the op is filled by wrapError.
+func newOpError(obj Storable, err error) error {
+	return &OpError{
+		Bucket: obj.BoltBucket(),
+		Key:    obj.BoltKey(),
+		Err:    err,
+	}
+}
+
+// begin starts a transaction, it gives up waiting for the transaction and returns ctx.Err() once ctx is done.
+func (d *DB) begin(ctx context.Context, writable bool) (*bbolt.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if d.broken != nil {
+		return nil, d.broken
+	}
+	if ctx.Done() == nil {
+		return d.db.Begin(writable)
+	}
+
+	type result struct {
+		tx  *bbolt.Tx
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		tx, err := d.db.Begin(writable)
+		ch <- result{tx: tx, err: err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.tx, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.tx != nil {
+				rollback(r.tx)
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// batch runs fn with bbolt.DB.Batch, see BatchPut.
+func (d *DB) batch(fn func(tx *bbolt.Tx) error) error {
+	if d.broken != nil {
+		return d.broken
+	}
+	return d.db.Batch(fn)
+}
+
+// update runs fn in a writable transaction like bbolt.DB.Update, but starts the transaction with ctx.
+func (d *DB) update(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	tx, err := d.begin(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
 
@@ -544,3 +1236,13 @@ func (d *DB) getCoder(obj any) Coder {
 	}
 	return d.defaultCoder
 }
+
+func (d *DB) getKeyDecoder(obj any) KeyDecoder {
+	if v, ok := obj.(HasKeyDecoder); ok {
+		return v.BoltKeyDecoder()
+	}
+	if d.defaultKeyDecoder == nil {
+		return BinaryKeyDecoder{}
+	}
+	return d.defaultKeyDecoder
+}
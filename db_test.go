@@ -1,6 +1,7 @@
 package boltutil
 
 import (
+	"context"
 	"path/filepath"
 	"reflect"
 	"testing"
@@ -497,3 +498,327 @@ func TestDB_BeforePut(t *testing.T) {
 
 	})
 }
+
+func TestDB_ScanMap(t *testing.T) {
+	type args struct {
+		result any
+		cond   *Filter
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantLen int
+		wantErr bool
+	}{
+		{
+			name: "regular",
+			args: args{
+				result: func() any {
+					var ret map[string]*Person
+					return &ret
+				}(),
+			},
+			wantLen: 2,
+			wantErr: false,
+		},
+		{
+			name: "with filter",
+			args: args{
+				result: func() any {
+					ret := map[string]*Person{}
+					return &ret
+				}(),
+				cond: NewFilter().SetPrefix([]byte("j")),
+			},
+			wantLen: 1,
+			wantErr: false,
+		},
+		{
+			name: "not pointer",
+			args: args{
+				result: map[string]*Person{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "not map",
+			args: args{
+				result: &Person{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "not empty",
+			args: args{
+				result: func() any {
+					ret := map[string]*Person{"": nil}
+					return &ret
+				}(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "wrong item",
+			args: args{
+				result: func() any {
+					var ret map[string]Person
+					return &ret
+				}(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "not storable",
+			args: args{
+				result: func() any {
+					var ret map[string]*string
+					return &ret
+				}(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "wrong key",
+			args: args{
+				result: func() any {
+					var ret map[uint64]*Person
+					return &ret
+				}(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "bucket not exists",
+			args: args{
+				result: func() any {
+					var ret map[string]*Wind
+					return &ret
+				}(),
+			},
+			wantErr: false,
+		},
+		{
+			name: "can not decode",
+			args: args{
+				result: func() any {
+					var ret map[uint32]*Car
+					return &ret
+				}(),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testDB(t).ScanMap(tt.args.result, tt.args.cond)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ScanMap() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				if got := reflect.ValueOf(tt.args.result).Elem().Len(); got != tt.wantLen {
+					t.Errorf("ScanMap() len = %v, want %v", got, tt.wantLen)
+				}
+			}
+		})
+	}
+
+	t.Run("integer key", func(t *testing.T) {
+		db := testDB(t, true)
+		require.NoError(t, db.MPut(
+			&Car{Id: 1, Name: "tesla"},
+			&Car{Id: 300, Name: "ya di"},
+		))
+		var got map[uint32]*Car
+		require.NoError(t, db.ScanMap(&got))
+		require.Len(t, got, 2)
+		require.Equal(t, "tesla", got[1].Name)
+		require.Equal(t, "ya di", got[300].Name)
+	})
+}
+
+func TestDB_Hooks(t *testing.T) {
+	titleIndex := func(db *DB, title string) []byte {
+		var ret []byte
+		_ = db.Unwrap().View(func(tx *bbolt.Tx) error {
+			if bucket := tx.Bucket([]byte("book_title")); bucket != nil {
+				ret = bucket.Get([]byte(title))
+			}
+			return nil
+		})
+		return ret
+	}
+
+	t.Run("validate", func(t *testing.T) {
+		db := testDB(t, true)
+		err := db.Put(&Book{Id: "1"})
+		require.ErrorIs(t, err, ErrInvalid)
+
+		err = db.MPut(&Book{Id: "1", Title: "Go"}, &Book{Id: "2"})
+		require.ErrorIs(t, err, ErrInvalid)
+		exist, err := db.Exist(&Book{Id: "1"})
+		require.NoError(t, err)
+		require.False(t, exist)
+	})
+
+	t.Run("after put", func(t *testing.T) {
+		db := testDB(t, true)
+		require.NoError(t, db.Put(&Book{Id: "1", Title: "Go"}))
+		require.Equal(t, []byte("1"), titleIndex(db, "Go"))
+	})
+
+	t.Run("after get", func(t *testing.T) {
+		db := testDB(t, true)
+		require.NoError(t, db.Put(&Book{Id: "1", Title: " Go "}))
+
+		book := &Book{Id: "1"}
+		require.NoError(t, db.Get(book))
+		require.Equal(t, "Go", book.Title)
+
+		var books []*Book
+		require.NoError(t, db.Scan(&books))
+		require.Len(t, books, 1)
+		require.Equal(t, "Go", books[0].Title)
+	})
+
+	t.Run("before delete", func(t *testing.T) {
+		db := testDB(t, true)
+		require.NoError(t, db.MPut(&Book{Id: "1", Title: "Go", Keep: true}, &Book{Id: "2", Title: "Rust"}))
+
+		require.Error(t, db.Delete(&Book{Id: "1", Keep: true}))
+		require.NoError(t, db.Delete(&Book{Id: "2", Title: "Rust"}))
+		require.Nil(t, titleIndex(db, "Rust"))
+
+		_, err := db.DeleteWhere(&Book{}, nil)
+		require.Error(t, err)
+		exist, err := db.Exist(&Book{Id: "1"})
+		require.NoError(t, err)
+		require.True(t, exist)
+	})
+}
+
+func TestDB_BeforeInsert(t *testing.T) {
+	t.Run("insert and update", func(t *testing.T) {
+		db := testDB(t, true)
+		ticket := &Ticket{Title: "first"}
+		require.NoError(t, db.Put(ticket))
+		require.Equal(t, uint64(1), ticket.Id)
+		require.False(t, ticket.CreatedAt.IsZero())
+		createdAt := ticket.CreatedAt
+
+		ticket.Title = "first again"
+		require.NoError(t, db.Put(ticket))
+		require.Equal(t, uint64(1), ticket.Id)
+		require.Equal(t, createdAt, ticket.CreatedAt)
+		require.True(t, ticket.UpdatedAt.After(createdAt))
+
+		second := &Ticket{Title: "second"}
+		require.NoError(t, db.MPut(second))
+		require.Equal(t, uint64(2), second.Id)
+	})
+
+	t.Run("sequence", func(t *testing.T) {
+		db := testDB(t, true)
+		id, err := db.NextSequence(&Ticket{})
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), id)
+
+		require.NoError(t, db.SetSequence(&Ticket{}, 100))
+		ticket := &Ticket{Title: "first"}
+		require.NoError(t, db.Put(ticket))
+		require.Equal(t, uint64(101), ticket.Id)
+
+		id, err = db.NextSequence(&Ticket{})
+		require.NoError(t, err)
+		require.Equal(t, uint64(102), id)
+	})
+}
+
+func TestDB_MGetPartial(t *testing.T) {
+	db := testDB(t)
+
+	t.Run("regular", func(t *testing.T) {
+		objs := []Storable{
+			&Person{Id: "jason"},
+			&Person{Id: "trump"},
+			&Wind{},
+			&Car{Id: 0},
+			&Person{Id: "vivia"},
+		}
+		got, err := db.MGetPartial(objs)
+		require.NoError(t, err)
+		require.Equal(t, []bool{true, false, false, false, true}, got.Found)
+		require.NoError(t, got.Errs[0])
+		require.ErrorIs(t, got.Errs[1], ErrKeyNotExist)
+		require.ErrorIs(t, got.Errs[2], ErrBucketNotExist)
+		require.Error(t, got.Errs[3])
+		require.NoError(t, got.Errs[4])
+		require.Error(t, got.Err())
+		require.Equal(t, "Jason Song", objs[0].(*Person).Name)
+		require.Equal(t, "Vivia Lei", objs[4].(*Person).Name)
+	})
+
+	t.Run("ignore if not exist", func(t *testing.T) {
+		objs := []Storable{
+			&Person{Id: "trump"},
+			&Wind{},
+			&Person{Id: "vivia"},
+		}
+		got, err := db.MGetPartial(objs, NewCondition().IgnoreIfNotExist())
+		require.NoError(t, err)
+		require.Equal(t, []bool{false, false, true}, got.Found)
+		require.NoError(t, got.Err())
+	})
+
+	t.Run("too many conditions", func(t *testing.T) {
+		_, err := db.MGetPartial(nil, nil, nil)
+		require.ErrorIs(t, err, ErrTooManyConditions)
+	})
+}
+
+func TestDB_Context(t *testing.T) {
+	t.Run("canceled", func(t *testing.T) {
+		db := testDB(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		require.ErrorIs(t, db.GetContext(ctx, &Person{Id: "jason"}), context.Canceled)
+		require.ErrorIs(t, db.PutContext(ctx, &Person{Id: "jason"}), context.Canceled)
+		require.ErrorIs(t, db.MPutContext(ctx, &Person{Id: "jason"}), context.Canceled)
+		require.ErrorIs(t, db.BatchPutContext(ctx, &Person{Id: "jason"}), context.Canceled)
+		var persons []*Person
+		require.ErrorIs(t, db.ScanContext(ctx, &persons), context.Canceled)
+	})
+
+	t.Run("cancel while scanning", func(t *testing.T) {
+		db := testDB(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var persons []*Person
+		err := db.ScanContext(ctx, &persons, NewFilter().AddStorableCondition(func(obj Storable) (bool, bool) {
+			cancel()
+			return false, false
+		}))
+		require.ErrorIs(t, err, context.Canceled)
+
+		_, err = db.DeleteWhereContext(ctx, &Person{}, nil)
+		require.ErrorIs(t, err, context.Canceled)
+		count, err := db.Count(&Person{})
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+	})
+
+	t.Run("deadline while waiting for lock", func(t *testing.T) {
+		db := testDB(t)
+		tx, err := db.Unwrap().Begin(true)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, db.PutContext(ctx, &Person{Id: "jason"}), context.DeadlineExceeded)
+
+		require.NoError(t, tx.Rollback())
+		require.NoError(t, db.PutContext(context.Background(), &Person{Id: "jason", Name: "Jason"}))
+	})
+}
@@ -1,8 +1,100 @@
 package boltutil
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
-	ErrNotExist     = errors.New("not exist")
-	ErrAlreadyExist = errors.New("already exist")
+	ErrNotExist       = errors.New("not exist")
+	ErrBucketNotExist = &kindError{msg: "bucket not exist", kind: ErrNotExist} // matches ErrNotExist
+	ErrKeyNotExist    = &kindError{msg: "key not exist", kind: ErrNotExist}    // matches ErrNotExist
+	ErrAlreadyExist   = errors.New("already exist")
+	ErrInvalid        = errors.New("invalid")
+
+	ErrTooManyConditions = errors.New("too many conditions")
+	ErrTooManyFilters    = errors.New("too many filters")
 )
+
+// kindError is an error which is a more specific kind of another error.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// OpError is the error returned by the operations of DB,
+// it records the operation, and the bucket and the key which caused the error if known.
+type OpError struct {
+	Op     string // the operation, like "get", "mput" or "scan"
+	Bucket []byte // nil if unknown
+	Key    []byte // nil if unknown
+	Err    error
+}
+
+func (e *OpError) Error() string {
+	sb := &strings.Builder{}
+	sb.WriteString(e.Op)
+	if e.Bucket != nil {
+		_, _ = fmt.Fprintf(sb, " bucket %q", e.Bucket)
+	}
+	if e.Key != nil {
+		_, _ = fmt.Fprintf(sb, " key %q", e.Key)
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// wrapError makes *err an *OpError of op, an *OpError returned by inner functions only needs the op to be filled.
+// It is supposed to be deferred with the named error result.
+func wrapError(err *error, op string, bucket, key []byte) {
+	if *err == nil {
+		return
+	}
+	if e, ok := (*err).(*OpError); ok {
+		if e.Op == "" {
+			e.Op = op
+		}
+		return
+	}
+	*err = &OpError{
+		Op:     op,
+		Bucket: bucket,
+		Key:    key,
+		Err:    *err,
+	}
+}
+
+// codecError is the error of encoding or decoding, it is logged at warn level.
+type codecError struct {
+	msg string
+	err error
+}
+
+func newCodecError(op string, v any, err error) error {
+	return &codecError{
+		msg: fmt.Sprintf("%s %T", op, v),
+		err: err,
+	}
+}
+
+func (e *codecError) Error() string {
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e *codecError) Unwrap() error {
+	return e.err
+}
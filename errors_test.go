@@ -0,0 +1,74 @@
+package boltutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpError(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	t.Run("key not exist", func(t *testing.T) {
+		err := db.Get(&Person{Id: "trump"})
+		var opErr *OpError
+		require.True(t, errors.As(err, &opErr))
+		assert.Equal(t, "get", opErr.Op)
+		assert.Equal(t, []byte("person"), opErr.Bucket)
+		assert.Equal(t, []byte("trump"), opErr.Key)
+		assert.ErrorIs(t, err, ErrKeyNotExist)
+		assert.ErrorIs(t, err, ErrNotExist)
+		assert.NotErrorIs(t, err, ErrBucketNotExist)
+		assert.Equal(t, `get bucket "person" key "trump": key not exist`, err.Error())
+	})
+
+	t.Run("bucket not exist", func(t *testing.T) {
+		err := db.MGet(&Person{Id: "jason"}, &Book{Id: "1"})
+		var opErr *OpError
+		require.True(t, errors.As(err, &opErr))
+		assert.Equal(t, "mget", opErr.Op)
+		assert.Equal(t, []byte("book"), opErr.Bucket)
+		assert.Equal(t, []byte("1"), opErr.Key)
+		assert.ErrorIs(t, err, ErrBucketNotExist)
+		assert.ErrorIs(t, err, ErrNotExist)
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		var cars []*Car
+		err := db.Scan(&cars)
+		var opErr *OpError
+		require.True(t, errors.As(err, &opErr))
+		assert.Equal(t, "scan", opErr.Op)
+		assert.Equal(t, []byte("car"), opErr.Bucket)
+		assert.Equal(t, (&Car{Id: 0}).BoltKey(), opErr.Key)
+	})
+
+	t.Run("already exist", func(t *testing.T) {
+		err := db.Put(&Person{Id: "jason"}, NewCondition().FailIfExist())
+		assert.ErrorIs(t, err, ErrAlreadyExist)
+		assert.Equal(t, `put bucket "person" key "jason": already exist`, err.Error())
+	})
+
+	t.Run("mput", func(t *testing.T) {
+		err := db.MPut(&Book{Id: "1", Title: "Go"}, &Book{Id: "2"})
+		var opErr *OpError
+		require.True(t, errors.As(err, &opErr))
+		assert.Equal(t, "mput", opErr.Op)
+		assert.Equal(t, []byte("2"), opErr.Key)
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		assert.ErrorIs(t, db.Get(&Person{Id: "jason"}, nil, nil), ErrTooManyConditions)
+		assert.ErrorIs(t, db.Put(&Person{Id: "jason"}, nil, nil), ErrTooManyConditions)
+		assert.ErrorIs(t, db.Delete(&Person{Id: "jason"}, nil, nil), ErrTooManyConditions)
+		var persons []*Person
+		assert.ErrorIs(t, db.Scan(&persons, nil, nil), ErrTooManyFilters)
+		_, err := db.Count(&Person{}, nil, nil)
+		assert.ErrorIs(t, err, ErrTooManyFilters)
+		assert.ErrorIs(t, db.First(&Person{}, nil, nil), ErrTooManyFilters)
+	})
+}
@@ -0,0 +1,87 @@
+package boltutil
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// expvarBuckets are the upper bounds of the duration histogram of ExpvarObserver.
+var expvarBuckets = []struct {
+	label string
+	le    time.Duration
+}{
+	{"le_100us", 100 * time.Microsecond},
+	{"le_1ms", time.Millisecond},
+	{"le_10ms", 10 * time.Millisecond},
+	{"le_100ms", 100 * time.Millisecond},
+	{"le_1s", time.Second},
+}
+
+// ExpvarObserver implements Observer, it publishes metrics of every operation with the standard expvar package.
+//
+// The published variable is a map keyed by operation, and each operation is a map of:
+//   - calls: count of calls;
+//   - errors: count of calls failed;
+//   - bytes: bytes of values encoded and decoded;
+//   - duration_ns: total time spent in nanoseconds;
+//   - duration_histogram: cumulative count of calls by duration, keyed by upper bound like "le_1ms" and "le_inf".
+type ExpvarObserver struct {
+	ops *expvar.Map
+	mu  sync.Mutex // protects initializing of the maps of operations
+}
+
+// NewExpvarObserver returns an ExpvarObserver which publishes metrics with the name,
+// it panics if the name is already registered, like expvar.Publish.
+func NewExpvarObserver(name string) *ExpvarObserver {
+	return &ExpvarObserver{
+		ops: expvar.NewMap(name),
+	}
+}
+
+func (o *ExpvarObserver) Observe(event *Event) {
+	m, ok := o.ops.Get(event.Op).(*expvar.Map)
+	if !ok {
+		m = o.newOpMap(event.Op)
+	}
+
+	m.Add("calls", 1)
+	if event.Err != nil {
+		m.Add("errors", 1)
+	}
+	m.Add("bytes", int64(event.Bytes))
+	m.Add("duration_ns", int64(event.Duration))
+
+	histogram := m.Get("duration_histogram").(*expvar.Map)
+	for _, v := range expvarBuckets {
+		if event.Duration <= v.le {
+			histogram.Add(v.label, 1)
+		}
+	}
+	histogram.Add("le_inf", 1)
+}
+
+// newOpMap initializes the map of the operation, so all metrics are present even if they are zero.
+func (o *ExpvarObserver) newOpMap(op string) *expvar.Map {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if v, ok := o.ops.Get(op).(*expvar.Map); ok {
+		return v
+	}
+
+	m := &expvar.Map{}
+	m.Init()
+	for _, name := range []string{"calls", "errors", "bytes", "duration_ns"} {
+		m.Set(name, &expvar.Int{})
+	}
+	histogram := &expvar.Map{}
+	histogram.Init()
+	for _, v := range expvarBuckets {
+		histogram.Set(v.label, &expvar.Int{})
+	}
+	histogram.Set("le_inf", &expvar.Int{})
+	m.Set("duration_histogram", histogram)
+
+	o.ops.Set(op, m)
+	return m
+}
@@ -0,0 +1,141 @@
+package boltutil
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fieldByName returns the exported field of the struct which obj points to,
+// nested fields can be accessed with dots, like "Owner.Name".
+func fieldByName(obj any, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(obj)
+	for _, part := range strings.Split(name, ".") {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		f, ok := v.Type().FieldByName(part)
+		if !ok || !f.IsExported() {
+			return reflect.Value{}, false
+		}
+		v = v.FieldByIndex(f.Index)
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// compareValues compares a and b, returns -1, 0 or +1, ok is false if they are not comparable.
+// Numbers of different kinds are comparable, and time.Time is comparable with a RFC 3339 string.
+func compareValues(a, b reflect.Value) (ret int, ok bool) {
+	switch {
+	case a.Type() == timeType:
+		t, ok := toTime(b)
+		if !ok {
+			return 0, false
+		}
+		return a.Interface().(time.Time).Compare(t), true
+	case b.Type() == timeType:
+		ret, ok := compareValues(b, a)
+		return -ret, ok
+	}
+
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch b.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareOrdered(a.Int(), b.Int()), true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if a.Int() < 0 {
+				return -1, true
+			}
+			return compareOrdered(uint64(a.Int()), b.Uint()), true
+		case reflect.Float32, reflect.Float64:
+			return compareOrdered(float64(a.Int()), b.Float()), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch b.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			ret, ok := compareValues(b, a)
+			return -ret, ok
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return compareOrdered(a.Uint(), b.Uint()), true
+		case reflect.Float32, reflect.Float64:
+			return compareOrdered(float64(a.Uint()), b.Float()), true
+		}
+	case reflect.Float32, reflect.Float64:
+		switch b.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			ret, ok := compareValues(b, a)
+			return -ret, ok
+		case reflect.Float32, reflect.Float64:
+			return compareOrdered(a.Float(), b.Float()), true
+		}
+	case reflect.String:
+		if b.Kind() == reflect.String {
+			return strings.Compare(a.String(), b.String()), true
+		}
+	case reflect.Bool:
+		if b.Kind() == reflect.Bool {
+			x, y := 0, 0
+			if a.Bool() {
+				x = 1
+			}
+			if b.Bool() {
+				y = 1
+			}
+			return compareOrdered(x, y), true
+		}
+	}
+	return 0, false
+}
+
+func toTime(v reflect.Value) (time.Time, bool) {
+	switch {
+	case v.Type() == timeType:
+		return v.Interface().(time.Time), true
+	case v.Kind() == reflect.String:
+		t, err := time.Parse(time.RFC3339Nano, v.String())
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func compareOrdered[T int | int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// toFloat converts a numeric value to float64.
+func toFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
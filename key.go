@@ -0,0 +1,77 @@
+package boltutil
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// KeyDecoder is the interface that can decode bolt keys into map keys.
+type KeyDecoder interface {
+	DecodeKey(key []byte, v any) error
+}
+
+// BinaryKeyDecoder implements KeyDecoder, it supports:
+//   - types implementing encoding.BinaryUnmarshaler or encoding.TextUnmarshaler;
+//   - strings;
+//   - integers, encoded in big-endian with the size of the type;
+//   - byte arrays, with the same length as the key.
+type BinaryKeyDecoder struct {
+}
+
+func (d BinaryKeyDecoder) DecodeKey(key []byte, v any) error {
+	switch u := v.(type) {
+	case encoding.BinaryUnmarshaler:
+		return u.UnmarshalBinary(key)
+	case encoding.TextUnmarshaler:
+		return u.UnmarshalText(key)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("should be non-nil pointer: %T", v)
+	}
+	rv = rv.Elem()
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(string(key))
+		return nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		size := int(rv.Type().Size())
+		if len(key) != size {
+			return fmt.Errorf("key length %d does not match %v", len(key), rv.Type())
+		}
+		var u uint64
+		switch size {
+		case 1:
+			u = uint64(key[0])
+		case 2:
+			u = uint64(binary.BigEndian.Uint16(key))
+		case 4:
+			u = uint64(binary.BigEndian.Uint32(key))
+		case 8:
+			u = binary.BigEndian.Uint64(key)
+		}
+		if rv.CanInt() {
+			// sign-extend the value with the size of the type
+			shift := 64 - 8*size
+			rv.SetInt(int64(u<<shift) >> shift)
+		} else {
+			rv.SetUint(u)
+		}
+		return nil
+	case reflect.Array:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		if len(key) != rv.Len() {
+			return fmt.Errorf("key length %d does not match %v", len(key), rv.Type())
+		}
+		reflect.Copy(rv, reflect.ValueOf(key))
+		return nil
+	}
+	return fmt.Errorf("unsupported key type: %v", rv.Type())
+}
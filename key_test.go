@@ -0,0 +1,58 @@
+package boltutil
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryKeyDecoder(t *testing.T) {
+	d := BinaryKeyDecoder{}
+
+	t.Run("string", func(t *testing.T) {
+		var got string
+		require.NoError(t, d.DecodeKey([]byte("jason"), &got))
+		assert.Equal(t, "jason", got)
+	})
+
+	t.Run("integers", func(t *testing.T) {
+		var u32 uint32
+		require.NoError(t, d.DecodeKey([]byte{0, 0, 1, 2}, &u32))
+		assert.Equal(t, uint32(258), u32)
+
+		var i16 int16
+		require.NoError(t, d.DecodeKey([]byte{0xff, 0xfe}, &i16))
+		assert.Equal(t, int16(-2), i16)
+
+		var i64 int64
+		require.NoError(t, d.DecodeKey([]byte{0, 0, 0, 0, 0, 0, 0, 7}, &i64))
+		assert.Equal(t, int64(7), i64)
+
+		var u8 uint8
+		require.NoError(t, d.DecodeKey([]byte{9}, &u8))
+		assert.Equal(t, uint8(9), u8)
+
+		assert.Error(t, d.DecodeKey([]byte{0, 1}, &u32))
+	})
+
+	t.Run("array", func(t *testing.T) {
+		var got [3]byte
+		require.NoError(t, d.DecodeKey([]byte{1, 2, 3}, &got))
+		assert.Equal(t, [3]byte{1, 2, 3}, got)
+		assert.Error(t, d.DecodeKey([]byte{1, 2}, &got))
+	})
+
+	t.Run("unmarshaler", func(t *testing.T) {
+		var got netip.Addr
+		require.NoError(t, d.DecodeKey([]byte{127, 0, 0, 1}, &got))
+		assert.Equal(t, netip.MustParseAddr("127.0.0.1"), got)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		var got float64
+		assert.Error(t, d.DecodeKey([]byte{1}, &got))
+		assert.Error(t, d.DecodeKey([]byte{1}, got))
+	})
+}
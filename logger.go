@@ -0,0 +1,74 @@
+package boltutil
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"unicode"
+	"unicode/utf8"
+)
+
+// log logs the event at debug level, or warn level if it is an encoding or decoding failure, or it is slow.
+func (d *DB) log(ctx context.Context, event *Event) {
+	attrs := []slog.Attr{
+		slog.String("op", event.Op),
+		slog.Duration("duration", event.Duration),
+		slog.Int("bytes", event.Bytes),
+	}
+	if event.Bucket != nil {
+		attrs = append(attrs, keyAttr("bucket", event.Bucket))
+	}
+
+	var codecErr *codecError
+	var opErr *OpError
+	if errors.As(event.Err, &codecErr) && errors.As(event.Err, &opErr) {
+		if opErr.Bucket != nil && event.Bucket == nil {
+			attrs = append(attrs, keyAttr("bucket", opErr.Bucket))
+		}
+		if opErr.Key != nil {
+			attrs = append(attrs, keyAttr("key", opErr.Key))
+		}
+		attrs = append(attrs, slog.Any("error", event.Err))
+		d.logger.LogAttrs(ctx, slog.LevelWarn, "boltutil codec failed", attrs...)
+		return
+	}
+
+	if len(event.Keys) == 1 {
+		attrs = append(attrs, keyAttr("key", event.Keys[0]))
+	} else if len(event.Keys) > 1 {
+		attrs = append(attrs, slog.Int("keys", len(event.Keys)))
+	}
+	if event.Err != nil {
+		attrs = append(attrs, slog.Any("error", event.Err))
+	}
+
+	if d.slowThreshold > 0 && event.Duration > d.slowThreshold {
+		attrs = append(attrs, slog.Duration("threshold", d.slowThreshold))
+		d.logger.LogAttrs(ctx, slog.LevelWarn, "boltutil operation slow", attrs...)
+		return
+	}
+	if d.logger.Enabled(ctx, slog.LevelDebug) {
+		d.logger.LogAttrs(ctx, slog.LevelDebug, "boltutil operation", attrs...)
+	}
+}
+
+// keyAttr returns an attribute of the bolt key or bucket name, as a string if it is printable, or in hex if it is not.
+func keyAttr(name string, key []byte) slog.Attr {
+	if isPrintable(key) {
+		return slog.String(name, string(key))
+	}
+	return slog.String(name, "0x"+hex.EncodeToString(key))
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package boltutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestWithLogger(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithLogger(logger))
+	require.NoError(t, err)
+	defer db.Close()
+
+	logs := func() []map[string]any {
+		var ret []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+			m := map[string]any{}
+			require.NoError(t, json.Unmarshal([]byte(line), &m))
+			ret = append(ret, m)
+		}
+		buffer.Reset()
+		return ret
+	}
+
+	t.Run("debug", func(t *testing.T) {
+		require.NoError(t, db.Put(&Person{Id: "jason", Name: "Jason Song"}))
+		got := logs()
+		require.Len(t, got, 1)
+		assert.Equal(t, "DEBUG", got[0]["level"])
+		assert.Equal(t, "put", got[0]["op"])
+		assert.Equal(t, "person", got[0]["bucket"])
+		assert.Equal(t, "jason", got[0]["key"])
+	})
+
+	t.Run("decode failure", func(t *testing.T) {
+		require.NoError(t, db.Unwrap().Update(func(tx *bbolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte("car"))
+			if err != nil {
+				return err
+			}
+			return bucket.Put((&Car{Id: 1}).BoltKey(), []byte("dirty"))
+		}))
+
+		require.Error(t, db.MGet(&Person{Id: "jason"}, &Car{Id: 1}))
+		got := logs()
+		require.Len(t, got, 1)
+		assert.Equal(t, "WARN", got[0]["level"])
+		assert.Equal(t, "mget", got[0]["op"])
+		assert.Equal(t, "car", got[0]["bucket"])
+		assert.Equal(t, "0x00000001", got[0]["key"])
+		assert.Contains(t, got[0]["error"], "decode *boltutil.Car")
+	})
+
+	t.Run("slow", func(t *testing.T) {
+		db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithLogger(logger), WithSlowThreshold(time.Nanosecond))
+		require.NoError(t, err)
+		defer db.Close()
+
+		require.NoError(t, db.Put(&Person{Id: "jason"}))
+		got := logs()
+		require.Len(t, got, 1)
+		assert.Equal(t, "WARN", got[0]["level"])
+		assert.Equal(t, "boltutil operation slow", got[0]["msg"])
+	})
+}
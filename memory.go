@@ -0,0 +1,549 @@
+package boltutil
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// MemDB is an in-memory implementation of Store, it follows DB in the order of keys, conditions, filters, coders
+// and the hooks which do not need a bbolt transaction, so it can replace DB in tests of the other types.
+// HasAfterPut and HasBeforeDelete take a bbolt transaction, which MemDB does not have,
+// so the operations on the types implementing them fail with errors.ErrUnsupported.
+// Like DB, a write operation is all or nothing.
+type MemDB struct {
+	d       *DB // the DB without database file, which provides coders, observer and logger
+	mu      sync.RWMutex
+	buckets map[string]*memBucket
+}
+
+// NewMemDB creates an in-memory database with given options, the options of the database file are ignored.
+func NewMemDB(options ...Option) *MemDB {
+	option := &innerOption{
+		DefaultCoder:      GobCoder{},
+		DefaultKeyDecoder: BinaryKeyDecoder{},
+		Options: func() *bbolt.Options {
+			v := *bbolt.DefaultOptions
+			return &v
+		}(),
+	}
+	for _, v := range options {
+		v(option)
+	}
+
+	return &MemDB{
+		d: &DB{
+			defaultCoder:      option.DefaultCoder,
+			defaultKeyDecoder: option.DefaultKeyDecoder,
+			observer:          option.Observer,
+			logger:            option.Logger,
+			slowThreshold:     option.SlowThreshold,
+			auditBucket:       option.AuditBucket,
+		},
+		buckets: map[string]*memBucket{},
+	}
+}
+
+// Get injects storable object with its key.
+func (m *MemDB) Get(obj Storable, conditions ...*Condition) (err error) {
+	op := m.d.newOperation(context.Background(), "get", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	var condition *Condition
+	if len(conditions) == 1 {
+		condition = conditions[0]
+	} else if len(conditions) > 1 {
+		return ErrTooManyConditions
+	}
+
+	return m.view(func(tx *memTx) error {
+		return m.d.get(op, tx, obj, condition, 0)
+	})
+}
+
+// Put stores storable object.
+func (m *MemDB) Put(obj Storable, conditions ...*Condition) (err error) {
+	op := m.d.newOperation(context.Background(), "put", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	var condition *Condition
+	if len(conditions) == 1 {
+		condition = conditions[0]
+	} else if len(conditions) > 1 {
+		return ErrTooManyConditions
+	}
+
+	return m.update(func(tx *memTx) error {
+		return m.d.putWithCondition(op, tx, obj, condition)
+	})
+}
+
+// Delete deletes storable object.
+func (m *MemDB) Delete(obj Storable, conditions ...*Condition) (err error) {
+	op := m.d.newOperation(context.Background(), "delete", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	var condition *Condition
+	if len(conditions) == 1 {
+		condition = conditions[0]
+	} else if len(conditions) > 1 {
+		return ErrTooManyConditions
+	}
+
+	return m.update(func(tx *memTx) error {
+		return m.d.deleteWithCondition(op, tx, obj, condition)
+	})
+}
+
+// MGet injects storable objects with their keys.
+func (m *MemDB) MGet(objs ...Storable) (err error) {
+	op := m.d.newOperation(context.Background(), "mget", nil, nil)
+	defer op.finish(&err)
+
+	return m.view(func(tx *memTx) error {
+		return m.d.mget(op, tx, objs, 0)
+	})
+}
+
+// MPut store storables into database, create bucket if it does not exist.
+func (m *MemDB) MPut(objs ...Storable) (err error) {
+	op := m.d.newOperation(context.Background(), "mput", nil, nil)
+	defer op.finish(&err)
+
+	return m.update(func(tx *memTx) error {
+		for _, obj := range objs {
+			if err := m.d.putWithCondition(op, tx, obj, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MDelete remove values by key of storables
+func (m *MemDB) MDelete(objs ...Storable) (err error) {
+	op := m.d.newOperation(context.Background(), "mdelete", nil, nil)
+	defer op.finish(&err)
+
+	return m.update(func(tx *memTx) error {
+		for _, obj := range objs {
+			if err := m.d.deleteWithCondition(op, tx, obj, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan scans values in the bucket and put them into result.
+func (m *MemDB) Scan(result any, filters ...*Filter) (err error) {
+	op := m.d.newOperation(context.Background(), "scan", nil, nil)
+	defer op.finish(&err)
+
+	var filter *Filter
+	if len(filters) == 1 {
+		filter = filters[0]
+	} else if len(filters) > 1 {
+		return ErrTooManyFilters
+	}
+
+	slice, itemType, bucketName, err := scanSlice(result)
+	if err != nil {
+		return err
+	}
+
+	return m.view(func(tx *memTx) error {
+		bucket := tx.db.buckets[string(bucketName)]
+		if bucket == nil {
+			return nil
+		}
+		return m.d.scan(op, bucket.cursor(), slice, itemType, filter)
+	})
+}
+
+// First injects the first value in the bucket into result.
+func (m *MemDB) First(obj Storable, filters ...*Filter) (err error) {
+	op := m.d.newOperation(context.Background(), "first", obj.BoltBucket(), nil)
+	defer op.finish(&err)
+
+	var filter *Filter
+	if len(filters) == 1 {
+		filter = filters[0]
+	} else if len(filters) > 1 {
+		return ErrTooManyFilters
+	}
+
+	return m.view(func(tx *memTx) error {
+		bucket := tx.db.buckets[string(obj.BoltBucket())]
+		if bucket == nil {
+			return nil
+		}
+		return m.d.first(op, bucket.cursor(), obj, filter)
+	})
+}
+
+// Count return count of kv in the bucket.
+func (m *MemDB) Count(obj Storable, filters ...*Filter) (_ int, err error) {
+	op := m.d.newOperation(context.Background(), "count", obj.BoltBucket(), nil)
+	defer op.finish(&err)
+
+	var filter *Filter
+	if len(filters) == 1 {
+		filter = filters[0]
+	} else if len(filters) > 1 {
+		return 0, ErrTooManyFilters
+	}
+
+	count := 0
+	if err := m.view(func(tx *memTx) error {
+		bucket := tx.db.buckets[string(obj.BoltBucket())]
+		if bucket == nil {
+			return nil
+		}
+		var err error
+		count, err = m.d.count(op, bucket.cursor(), obj, filter)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Exist check if the storable exist
+func (m *MemDB) Exist(obj Storable) (_ bool, err error) {
+	op := m.d.newOperation(context.Background(), "exist", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bucket := m.buckets[string(obj.BoltBucket())]
+	if bucket == nil {
+		return false, nil
+	}
+	return bucket.get(obj.BoltKey()) != nil, nil
+}
+
+// DeleteBucket remove the specified buckets
+func (m *MemDB) DeleteBucket(hasBuckets ...HasBucket) (err error) {
+	op := m.d.newOperation(context.Background(), "delete bucket", nil, nil)
+	defer op.finish(&err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, obj := range hasBuckets {
+		if err := m.d.checkAuditBucket(obj.BoltBucket()); err != nil {
+			return err
+		}
+	}
+	for _, obj := range hasBuckets {
+		delete(m.buckets, string(obj.BoltBucket()))
+	}
+	return nil
+}
+
+// DeleteAllBucket remove all buckets
+func (m *MemDB) DeleteAllBucket() (err error) {
+	op := m.d.newOperation(context.Background(), "delete all bucket", nil, nil)
+	defer op.finish(&err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name := range m.buckets {
+		if !m.d.isAuditBucket([]byte(name)) {
+			delete(m.buckets, name)
+		}
+	}
+	return nil
+}
+
+// Restore moves the soft deleted record of obj back to its bucket and injects it into obj.
+func (m *MemDB) Restore(obj Storable) (err error) {
+	op := m.d.newOperation(context.Background(), "restore", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	return m.update(func(tx *memTx) error {
+		return m.d.restore(op, tx, obj)
+	})
+}
+
+// PurgeDeleted removes the soft deleted records of all types which were deleted before the time,
+// returns the count of removed records.
+func (m *MemDB) PurgeDeleted(before time.Time) (_ int, err error) {
+	op := m.d.newOperation(context.Background(), "purge deleted", tombstoneBucket, nil)
+	defer op.finish(&err)
+
+	count := 0
+	if err := m.update(func(tx *memTx) error {
+		bucket := tx.db.buckets[string(tombstoneBucket)]
+		if bucket == nil {
+			return nil
+		}
+		var err error
+		count, err = m.d.purgeDeleted(op, &memTxBucket{tx: tx, bucket: bucket}, bucket.cursor(), before)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// AuditByKey returns the audit entries of the key of obj in order of sequence.
+// It fails with ErrBucketNotExist if the audit is disabled or there is no entry yet.
+func (m *MemDB) AuditByKey(obj Storable) (_ []*AuditEntry, err error) {
+	op := m.d.newOperation(context.Background(), "audit", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	var ret []*AuditEntry
+	if err := m.view(func(tx *memTx) error {
+		if m.d.auditBucket == nil {
+			return ErrBucketNotExist
+		}
+		audit, index := tx.bucket(m.d.auditBucket), tx.db.buckets[string(m.d.auditIndexBucket())]
+		if audit == nil || index == nil {
+			return ErrBucketNotExist
+		}
+		var err error
+		ret, err = m.d.auditByKey(op, audit, index.cursor(), obj)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// AuditBetween returns the audit entries recorded in [from, to) in order of time, a zero to means no upper bound.
+// It fails with ErrBucketNotExist if the audit is disabled or there is no entry yet.
+func (m *MemDB) AuditBetween(from, to time.Time) (_ []*AuditEntry, err error) {
+	op := m.d.newOperation(context.Background(), "audit", m.d.auditBucket, nil)
+	defer op.finish(&err)
+
+	var ret []*AuditEntry
+	if err := m.view(func(tx *memTx) error {
+		if m.d.auditBucket == nil {
+			return ErrBucketNotExist
+		}
+		audit, index := tx.bucket(m.d.auditBucket), tx.db.buckets[string(m.d.auditTimeBucket())]
+		if audit == nil || index == nil {
+			return ErrBucketNotExist
+		}
+		var err error
+		ret, err = m.d.auditBetween(op, audit, index.cursor(), from, to)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// view runs fn in a read-only transaction.
+func (m *MemDB) view(fn func(tx *memTx) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return fn(&memTx{db: m})
+}
+
+// update runs fn in a writable transaction, the changes are undone if fn fails.
+func (m *MemDB) update(fn func(tx *memTx) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &memTx{db: m, writable: true}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	return nil
+}
+
+// memTx implements kvTx for MemDB, it records how to undo the changes.
+type memTx struct {
+	db       *MemDB
+	writable bool
+	undo     []func()
+}
+
+func (tx *memTx) bucket(name []byte) kvBucket {
+	bucket := tx.db.buckets[string(name)]
+	if bucket == nil {
+		return nil
+	}
+	return &memTxBucket{tx: tx, bucket: bucket}
+}
+
+func (tx *memTx) createBucketIfNotExists(name []byte) (kvBucket, error) {
+	if !tx.writable {
+		return nil, bbolt.ErrTxNotWritable
+	}
+	if len(name) == 0 {
+		return nil, bbolt.ErrBucketNameRequired
+	}
+	if ret := tx.bucket(name); ret != nil {
+		return ret, nil
+	}
+
+	key := string(name)
+	bucket := &memBucket{}
+	tx.db.buckets[key] = bucket
+	tx.undo = append(tx.undo, func() {
+		delete(tx.db.buckets, key)
+	})
+	return &memTxBucket{tx: tx, bucket: bucket}, nil
+}
+
+func (tx *memTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
+
+// memBucket is a bucket of MemDB, the items are sorted by key.
+type memBucket struct {
+	items    []memItem
+	sequence uint64
+}
+
+type memItem struct {
+	key   []byte
+	value []byte
+}
+
+// search returns the index of the first item whose key is not less than key, and whether it is equal to key.
+func (b *memBucket) search(key []byte) (int, bool) {
+	i := sort.Search(len(b.items), func(i int) bool {
+		return bytes.Compare(b.items[i].key, key) >= 0
+	})
+	return i, i < len(b.items) && bytes.Equal(b.items[i].key, key)
+}
+
+func (b *memBucket) get(key []byte) []byte {
+	if i, ok := b.search(key); ok {
+		return b.items[i].value
+	}
+	return nil
+}
+
+func (b *memBucket) insert(i int, item memItem) {
+	b.items = append(b.items, memItem{})
+	copy(b.items[i+1:], b.items[i:])
+	b.items[i] = item
+}
+
+func (b *memBucket) remove(i int) {
+	b.items = append(b.items[:i], b.items[i+1:]...)
+}
+
+func (b *memBucket) cursor() cursor {
+	return &memCursor{bucket: b}
+}
+
+// memTxBucket implements kvBucket for a bucket of MemDB in a transaction.
+type memTxBucket struct {
+	tx     *memTx
+	bucket *memBucket
+}
+
+func (b *memTxBucket) Get(key []byte) []byte {
+	return b.bucket.get(key)
+}
+
+func (b *memTxBucket) Put(key []byte, value []byte) error {
+	switch {
+	case !b.tx.writable:
+		return bbolt.ErrTxNotWritable
+	case len(key) == 0:
+		return bbolt.ErrKeyRequired
+	case len(key) > bbolt.MaxKeySize:
+		return bbolt.ErrKeyTooLarge
+	case int64(len(value)) > bbolt.MaxValueSize:
+		return bbolt.ErrValueTooLarge
+	}
+
+	value = bytes.Clone(value)
+	i, ok := b.bucket.search(key)
+	if ok {
+		old := b.bucket.items[i].value
+		b.bucket.items[i].value = value
+		b.tx.undo = append(b.tx.undo, func() {
+			b.bucket.items[i].value = old
+		})
+		return nil
+	}
+	b.bucket.insert(i, memItem{key: bytes.Clone(key), value: value})
+	b.tx.undo = append(b.tx.undo, func() {
+		b.bucket.remove(i)
+	})
+	return nil
+}
+
+func (b *memTxBucket) Delete(key []byte) error {
+	if !b.tx.writable {
+		return bbolt.ErrTxNotWritable
+	}
+
+	i, ok := b.bucket.search(key)
+	if !ok {
+		return nil
+	}
+	item := b.bucket.items[i]
+	b.bucket.remove(i)
+	b.tx.undo = append(b.tx.undo, func() {
+		b.bucket.insert(i, item)
+	})
+	return nil
+}
+
+func (b *memTxBucket) NextSequence() (uint64, error) {
+	if !b.tx.writable {
+		return 0, bbolt.ErrTxNotWritable
+	}
+
+	b.bucket.sequence++
+	b.tx.undo = append(b.tx.undo, func() {
+		b.bucket.sequence--
+	})
+	return b.bucket.sequence, nil
+}
+
+func (b *memTxBucket) Tx() *bbolt.Tx {
+	return nil
+}
+
+// memCursor implements cursor for a bucket of MemDB.
+type memCursor struct {
+	bucket *memBucket
+	index  int
+}
+
+func (c *memCursor) First() ([]byte, []byte) {
+	c.index = 0
+	return c.current()
+}
+
+func (c *memCursor) Seek(seek []byte) ([]byte, []byte) {
+	c.index, _ = c.bucket.search(seek)
+	return c.current()
+}
+
+func (c *memCursor) Next() ([]byte, []byte) {
+	if c.index < len(c.bucket.items) {
+		c.index++
+	}
+	return c.current()
+}
+
+func (c *memCursor) current() ([]byte, []byte) {
+	if c.index >= len(c.bucket.items) {
+		return nil, nil
+	}
+	item := c.bucket.items[c.index]
+	return item.key, item.value
+}
@@ -0,0 +1,48 @@
+package boltutil
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMemDB(t *testing.T) {
+	observer := &recordObserver{}
+	db := NewMemDB(WithDefaultCoder(JsonCoder{}), WithObserver(observer))
+
+	require.NoError(t, db.Put(&Person{Id: "jason", Name: "Jason Song"}))
+	value := db.buckets["person"].get([]byte("jason"))
+	got := &Person{}
+	require.NoError(t, json.Unmarshal(value, got))
+	assert.Equal(t, "Jason Song", got.Name)
+
+	require.Len(t, observer.events, 1)
+	assert.Equal(t, "put", observer.events[0].Op)
+	assert.Equal(t, [][]byte{[]byte("jason")}, observer.events[0].Keys)
+}
+
+func TestMemDB_UnsupportedHooks(t *testing.T) {
+	db := NewMemDB()
+
+	err := db.MPut(&Person{Id: "jason"}, &Book{Id: "1", Title: "Go"})
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	assert.EqualError(t, err, `mput bucket "book" key "1": after put *boltutil.Book: unsupported operation`)
+	exist, err := db.Exist(&Person{Id: "jason"})
+	require.NoError(t, err)
+	assert.False(t, exist)
+	assert.Empty(t, db.buckets)
+
+	require.NoError(t, db.Put(&Person{Id: "jason"}))
+	require.NoError(t, db.update(func(tx *memTx) error {
+		_, err := tx.createBucketIfNotExists([]byte("book"))
+		return err
+	}))
+	err = db.MDelete(&Person{Id: "jason"}, &Book{Id: "1"})
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	exist, err = db.Exist(&Person{Id: "jason"})
+	require.NoError(t, err)
+	assert.True(t, exist)
+}
@@ -0,0 +1,109 @@
+package boltutil
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// Event describes a finished operation of DB.
+type Event struct {
+	Op       string        // the operation, like "get", "mput" or "scan"
+	Bucket   []byte        // nil if the operation is not bound to a bucket
+	Keys     [][]byte      // keys which are read or written by key, records visited by scans are not included
+	Duration time.Duration // time spent, including waiting for the transaction
+	Bytes    int           // bytes of values encoded and decoded
+	Err      error
+}
+
+// Observer is the interface that receives events of operations, it can be set with WithObserver.
+// Observe is called synchronously after every operation, so it should be fast and safe for concurrent use.
+type Observer interface {
+	Observe(event *Event)
+}
+
+// operation records the state of a running operation of DB.
+type operation struct {
+	ctx     context.Context
+	db      *DB
+	name    string
+	bucket  []byte
+	key     []byte
+	keys    [][]byte
+	bytes   int
+	start   time.Time
+	tracked bool   // whether the operation is observed or logged
+	unlock  func() // releases the lock of the DB taken by the operation
+}
+
+// newOperation starts an operation, key is used as the key of the errors and the events, it can be nil.
+func (d *DB) newOperation(ctx context.Context, name string, bucket, key []byte) *operation {
+	d.mu.RLock()
+	op := d.startOperation(ctx, name, bucket, key)
+	op.unlock = d.mu.RUnlock
+	return op
+}
+
+// newExclusiveOperation starts an operation like newOperation, but no other operation runs until it finishes.
+func (d *DB) newExclusiveOperation(ctx context.Context, name string, bucket, key []byte) *operation {
+	d.mu.Lock()
+	op := d.startOperation(ctx, name, bucket, key)
+	op.unlock = d.mu.Unlock
+	return op
+}
+
+func (d *DB) startOperation(ctx context.Context, name string, bucket, key []byte) *operation {
+	op := &operation{
+		ctx:     ctx,
+		db:      d,
+		name:    name,
+		bucket:  bucket,
+		key:     key,
+		tracked: d.observer != nil || d.logger != nil,
+	}
+	if op.tracked {
+		op.start = time.Now()
+		if key != nil {
+			op.keys = append(op.keys, key)
+		}
+	}
+	return op
+}
+
+// addKey records the key as a key of the events, it is ignored if the operation has a key already.
+func (op *operation) addKey(key []byte) {
+	if op.tracked && op.key == nil {
+		op.keys = append(op.keys, bytes.Clone(key))
+	}
+}
+
+// addBytes records n bytes encoded or decoded.
+func (op *operation) addBytes(n int) {
+	op.bytes += n
+}
+
+// finish wraps *err into an *OpError, notifies the observer and logs the operation,
+// it is supposed to be deferred with the named error result.
+func (op *operation) finish(err *error) {
+	// unlock before the observer and the logger, so they can call the DB
+	op.unlock()
+	wrapError(err, op.name, op.bucket, op.key)
+	if !op.tracked {
+		return
+	}
+
+	event := &Event{
+		Op:       op.name,
+		Bucket:   op.bucket,
+		Keys:     op.keys,
+		Duration: time.Since(op.start),
+		Bytes:    op.bytes,
+		Err:      *err,
+	}
+	if op.db.observer != nil {
+		op.db.observer.Observe(event)
+	}
+	if op.db.logger != nil {
+		op.db.log(op.ctx, event)
+	}
+}
@@ -0,0 +1,95 @@
+package boltutil
+
+import (
+	"encoding/json"
+	"expvar"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordObserver struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (o *recordObserver) Observe(event *Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, event)
+}
+
+func TestWithObserver(t *testing.T) {
+	observer := &recordObserver{}
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithObserver(observer))
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.Put(&Person{Id: "jason", Name: "Jason Song"}))
+	require.NoError(t, db.MPut(&Person{Id: "vivia"}, &Car{Id: 1}))
+	require.Error(t, db.Get(&Person{Id: "trump"}))
+	require.NoError(t, db.MGet(&Person{Id: "jason"}, &Person{Id: "vivia"}))
+	var persons []*Person
+	require.NoError(t, db.Scan(&persons))
+
+	require.Len(t, observer.events, 5)
+
+	put := observer.events[0]
+	assert.Equal(t, "put", put.Op)
+	assert.Equal(t, []byte("person"), put.Bucket)
+	assert.Equal(t, [][]byte{[]byte("jason")}, put.Keys)
+	assert.Positive(t, put.Bytes)
+	assert.Positive(t, put.Duration)
+	assert.NoError(t, put.Err)
+
+	mput := observer.events[1]
+	assert.Equal(t, "mput", mput.Op)
+	assert.Equal(t, [][]byte{[]byte("vivia"), (&Car{Id: 1}).BoltKey()}, mput.Keys)
+
+	get := observer.events[2]
+	assert.Equal(t, "get", get.Op)
+	assert.ErrorIs(t, get.Err, ErrKeyNotExist)
+	assert.Zero(t, get.Bytes)
+
+	mget := observer.events[3]
+	assert.Equal(t, [][]byte{[]byte("jason"), []byte("vivia")}, mget.Keys)
+	assert.Positive(t, mget.Bytes)
+
+	scan := observer.events[4]
+	assert.Equal(t, "scan", scan.Op)
+	assert.Empty(t, scan.Keys)
+	assert.Equal(t, mget.Bytes, scan.Bytes)
+}
+
+func TestExpvarObserver(t *testing.T) {
+	observer := NewExpvarObserver("boltutil_test")
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithObserver(observer))
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.Put(&Person{Id: "jason", Name: "Jason Song"}))
+	require.Error(t, db.Get(&Person{Id: "trump"}))
+	require.NoError(t, db.Get(&Person{Id: "jason"}))
+
+	got := map[string]struct {
+		Calls             int64            `json:"calls"`
+		Errors            int64            `json:"errors"`
+		Bytes             int64            `json:"bytes"`
+		DurationNs        int64            `json:"duration_ns"`
+		DurationHistogram map[string]int64 `json:"duration_histogram"`
+	}{}
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("boltutil_test").String()), &got))
+
+	assert.Equal(t, int64(1), got["put"].Calls)
+	assert.Equal(t, int64(0), got["put"].Errors)
+	assert.Positive(t, got["put"].Bytes)
+	assert.Equal(t, int64(2), got["get"].Calls)
+	assert.Equal(t, int64(1), got["get"].Errors)
+	assert.Equal(t, got["put"].Bytes, got["get"].Bytes)
+	assert.Positive(t, got["get"].DurationNs)
+	assert.Equal(t, int64(2), got["get"].DurationHistogram["le_inf"])
+	assert.Len(t, got["get"].DurationHistogram, 6)
+}
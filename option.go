@@ -1,6 +1,7 @@
 package boltutil
 
 import (
+	"log/slog"
 	"os"
 	"time"
 
@@ -8,9 +9,18 @@ import (
 )
 
 type innerOption struct {
-	FileMode     os.FileMode
-	DefaultCoder Coder
-	Options      *bbolt.Options
+	FileMode          os.FileMode
+	DefaultCoder      Coder
+	DefaultKeyDecoder KeyDecoder
+	MaxBatchSize      int
+	MaxBatchDelay     time.Duration
+	Observer          Observer
+	Logger            *slog.Logger
+	SlowThreshold     time.Duration
+	CacheMaxEntries   int
+	CacheMaxBytes     int
+	AuditBucket       []byte
+	Options           *bbolt.Options
 }
 
 // Option represents the options that can be set when opening a database.
@@ -30,6 +40,77 @@ func WithDefaultCoder(defaultCoder Coder) Option {
 	}
 }
 
+// WithDefaultKeyDecoder return Option with specified DefaultKeyDecoder
+func WithDefaultKeyDecoder(defaultKeyDecoder KeyDecoder) Option {
+	return func(options *innerOption) {
+		options.DefaultKeyDecoder = defaultKeyDecoder
+	}
+}
+
+// WithMaxBatchSize return Option with specified MaxBatchSize, which is used by BatchPut and BatchDelete
+func WithMaxBatchSize(maxBatchSize int) Option {
+	return func(options *innerOption) {
+		options.MaxBatchSize = maxBatchSize
+	}
+}
+
+// WithMaxBatchDelay return Option with specified MaxBatchDelay, which is used by BatchPut and BatchDelete
+func WithMaxBatchDelay(maxBatchDelay time.Duration) Option {
+	return func(options *innerOption) {
+		options.MaxBatchDelay = maxBatchDelay
+	}
+}
+
+// WithObserver return Option with specified Observer, which receives events of operations
+func WithObserver(observer Observer) Option {
+	return func(options *innerOption) {
+		options.Observer = observer
+	}
+}
+
+// WithLogger return Option with specified Logger, which logs operations at debug level,
+// and encoding or decoding failures and slow operations at warn level
+func WithLogger(logger *slog.Logger) Option {
+	return func(options *innerOption) {
+		options.Logger = logger
+	}
+}
+
+// WithSlowThreshold return Option with specified SlowThreshold, operations taking longer are logged as slow,
+// 0 means no slow logs
+func WithSlowThreshold(slowThreshold time.Duration) Option {
+	return func(options *innerOption) {
+		options.SlowThreshold = slowThreshold
+	}
+}
+
+// WithCacheMaxEntries return Option with specified CacheMaxEntries, objects got with Get and MGet are cached
+// in a LRU cache holding at most CacheMaxEntries objects, 0 means no limit of entries
+func WithCacheMaxEntries(cacheMaxEntries int) Option {
+	return func(options *innerOption) {
+		options.CacheMaxEntries = cacheMaxEntries
+	}
+}
+
+// WithCacheMaxBytes return Option with specified CacheMaxBytes, objects got with Get and MGet are cached
+// in a LRU cache holding at most CacheMaxBytes bytes of encoded values, 0 means no limit of bytes
+func WithCacheMaxBytes(cacheMaxBytes int) Option {
+	return func(options *innerOption) {
+		options.CacheMaxBytes = cacheMaxBytes
+	}
+}
+
+// WithAuditBucket return Option with specified AuditBucket, the changes made by Put, MPut, Delete, MDelete
+// and the other writes of objects are recorded in the bucket within the same transactions, nil means no audit.
+// The entries are indexed by key in the bucket named AuditBucket with "_index" appended, and by time
+// in the bucket named AuditBucket with "_time" appended,
+// the buckets are kept by DeleteAllBucket and can not be deleted, renamed or overwritten
+func WithAuditBucket(auditBucket []byte) Option {
+	return func(options *innerOption) {
+		options.AuditBucket = auditBucket
+	}
+}
+
 // WithTimeout return Option with specified Timeout
 func WithTimeout(timeout time.Duration) Option {
 	return func(options *innerOption) {
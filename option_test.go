@@ -1,6 +1,7 @@
 package boltutil
 
 import (
+	"log/slog"
 	"os"
 	"reflect"
 	"testing"
@@ -11,8 +12,17 @@ import (
 
 func TestWithOption(t *testing.T) {
 	want := &innerOption{
-		FileMode:     0600,
-		DefaultCoder: XmlCoder{},
+		FileMode:          0600,
+		DefaultCoder:      XmlCoder{},
+		DefaultKeyDecoder: BinaryKeyDecoder{},
+		MaxBatchSize:      100,
+		MaxBatchDelay:     time.Millisecond,
+		Observer:          &recordObserver{},
+		Logger:            slog.Default(),
+		SlowThreshold:     time.Second,
+		CacheMaxEntries:   1000,
+		CacheMaxBytes:     1 << 20,
+		AuditBucket:       []byte("audit"),
 		Options: &bbolt.Options{
 			Timeout:         time.Second,
 			NoGrowSync:      true,
@@ -30,6 +40,15 @@ func TestWithOption(t *testing.T) {
 	options := []Option{
 		WithFileMode(want.FileMode),
 		WithDefaultCoder(want.DefaultCoder),
+		WithDefaultKeyDecoder(want.DefaultKeyDecoder),
+		WithMaxBatchSize(want.MaxBatchSize),
+		WithMaxBatchDelay(want.MaxBatchDelay),
+		WithObserver(want.Observer),
+		WithLogger(want.Logger),
+		WithSlowThreshold(want.SlowThreshold),
+		WithCacheMaxEntries(want.CacheMaxEntries),
+		WithCacheMaxBytes(want.CacheMaxBytes),
+		WithAuditBucket(want.AuditBucket),
 		WithTimeout(want.Options.Timeout),
 		WithNoGrowSync(want.Options.NoGrowSync),
 		WithNoFreelistSync(want.Options.NoFreelistSync),
@@ -0,0 +1,385 @@
+package boltutil
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseFilter parses a textual query into a Filter, so filters can be accepted from config files or tools.
+//
+// The syntax is a list of expressions joined by "and", optionally followed by a limit:
+//
+//	prefix:"user/" and Age >= 18 and Name != "root" limit 50
+//
+// Supported expressions:
+//   - prefix:"<string>" restricts keys to the prefix.
+//   - key <op> "<string>" compares the raw bolt key.
+//   - <Field> <op> <value> compares the exported field of the decoded object, nested fields can be accessed
+//     with dots, like Owner.Name. Records which have no such field or an incomparable field are skipped.
+//
+// Operators are =, ==, !=, <, <=, > and >=. Values are double-quoted or back-quoted strings, numbers,
+// true or false. A time.Time field is compared with a RFC 3339 string. Keywords are case-insensitive.
+func ParseFilter(query string) (*Filter, error) {
+	p := &parser{
+		lexer: lexer{
+			query: query,
+		},
+	}
+	return p.parse()
+}
+
+// ParseError is the error returned by ParseFilter.
+type ParseError struct {
+	Query  string
+	Offset int // byte offset in Query where the error occurs
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse filter %q: offset %d: %s", e.Query, e.Offset, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenColon
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of query"
+	case tokenIdent:
+		return "identifier"
+	case tokenString:
+		return "string"
+	case tokenNumber:
+		return "number"
+	case tokenOperator:
+		return "operator"
+	case tokenColon:
+		return `":"`
+	}
+	return "unknown"
+}
+
+type token struct {
+	kind   tokenKind
+	text   string // raw text for most tokens, unquoted value for strings
+	offset int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return t.kind.String()
+	}
+	return fmt.Sprintf("%s %q", t.kind, t.text)
+}
+
+type lexer struct {
+	query  string
+	offset int
+}
+
+func (l *lexer) errorf(offset int, format string, args ...any) error {
+	return &ParseError{
+		Query:  l.query,
+		Offset: offset,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	for l.offset < len(l.query) && unicode.IsSpace(rune(l.query[l.offset])) {
+		l.offset++
+	}
+	start := l.offset
+	if start >= len(l.query) {
+		return token{kind: tokenEOF, offset: start}, nil
+	}
+
+	c := l.query[start]
+	switch {
+	case c == ':':
+		l.offset++
+		return token{kind: tokenColon, text: ":", offset: start}, nil
+	case c == '=' || c == '!' || c == '<' || c == '>':
+		l.offset++
+		if l.offset < len(l.query) && l.query[l.offset] == '=' {
+			l.offset++
+		}
+		text := l.query[start:l.offset]
+		if text == "!" {
+			return token{}, l.errorf(start, `unexpected "!", expect "!="`)
+		}
+		return token{kind: tokenOperator, text: text, offset: start}, nil
+	case c == '"' || c == '`':
+		end := start + 1
+		for ; end < len(l.query); end++ {
+			if l.query[end] == '\\' && c == '"' {
+				end++
+				continue
+			}
+			if l.query[end] == c {
+				break
+			}
+		}
+		if end >= len(l.query) {
+			return token{}, l.errorf(start, "unterminated string")
+		}
+		l.offset = end + 1
+		text, err := strconv.Unquote(l.query[start:l.offset])
+		if err != nil {
+			return token{}, l.errorf(start, "invalid string %s: %v", l.query[start:l.offset], err)
+		}
+		return token{kind: tokenString, text: text, offset: start}, nil
+	case c == '-' || c == '+' || c == '.' || isDigit(c):
+		l.offset++
+		for l.offset < len(l.query) {
+			c := l.query[l.offset]
+			if !isDigit(c) && c != '.' && c != 'e' && c != 'E' &&
+				!((c == '-' || c == '+') && (l.query[l.offset-1] == 'e' || l.query[l.offset-1] == 'E')) {
+				break
+			}
+			l.offset++
+		}
+		return token{kind: tokenNumber, text: l.query[start:l.offset], offset: start}, nil
+	case isIdentStart(c):
+		l.offset++
+		for l.offset < len(l.query) && (isIdentStart(l.query[l.offset]) || isDigit(l.query[l.offset]) || l.query[l.offset] == '.') {
+			l.offset++
+		}
+		return token{kind: tokenIdent, text: l.query[start:l.offset], offset: start}, nil
+	}
+	return token{}, l.errorf(start, "unexpected character %q", c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+type parser struct {
+	lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	tok := p.tok
+	if tok.kind != kind {
+		return tok, p.errorf(tok.offset, "unexpected %v, expect %v", tok, kind)
+	}
+	return tok, p.advance()
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	return p.tok.kind == tokenIdent && strings.EqualFold(p.tok.text, keyword)
+}
+
+func (p *parser) parse() (*Filter, error) {
+	filter := NewFilter()
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokenEOF && !p.isKeyword("limit") {
+		for {
+			if err := p.parseExpr(filter); err != nil {
+				return nil, err
+			}
+			if !p.isKeyword("and") {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if p.isKeyword("limit") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		tok, err := p.expect(tokenNumber)
+		if err != nil {
+			return nil, err
+		}
+		limit, err := strconv.Atoi(tok.text)
+		if err != nil || limit < 0 {
+			return nil, p.errorf(tok.offset, "invalid limit %q", tok.text)
+		}
+		filter.SetLimit(limit)
+	}
+
+	if p.tok.kind != tokenEOF {
+		return nil, p.errorf(p.tok.offset, "unexpected %v, expect %q, %q or end of query", p.tok, "and", "limit")
+	}
+	return filter, nil
+}
+
+func (p *parser) parseExpr(filter *Filter) error {
+	name, err := p.expect(tokenIdent)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case strings.EqualFold(name.text, "prefix"):
+		if _, err := p.expect(tokenColon); err != nil {
+			return err
+		}
+		value, err := p.expect(tokenString)
+		if err != nil {
+			return err
+		}
+		filter.SetPrefix([]byte(value.text))
+		return nil
+	case strings.EqualFold(name.text, "key"):
+		op, err := p.parseOperator()
+		if err != nil {
+			return err
+		}
+		value, err := p.expect(tokenString)
+		if err != nil {
+			return err
+		}
+		addKeyCondition(filter, op, []byte(value.text))
+		return nil
+	case strings.EqualFold(name.text, "and") || strings.EqualFold(name.text, "limit"):
+		return p.errorf(name.offset, "unexpected keyword %q, expect expression", name.text)
+	}
+
+	op, err := p.parseOperator()
+	if err != nil {
+		return err
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	filter.AddStorableCondition(fieldCondition(name.text, op, value))
+	return nil
+}
+
+func (p *parser) parseOperator() (string, error) {
+	tok, err := p.expect(tokenOperator)
+	if err != nil {
+		return "", err
+	}
+	if tok.text == "==" {
+		return "=", nil
+	}
+	return tok.text, nil
+}
+
+func (p *parser) parseValue() (reflect.Value, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokenString:
+		return reflect.ValueOf(tok.text), p.advance()
+	case tok.kind == tokenNumber:
+		if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return reflect.ValueOf(i), p.advance()
+		}
+		if u, err := strconv.ParseUint(tok.text, 10, 64); err == nil {
+			return reflect.ValueOf(u), p.advance()
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return reflect.Value{}, p.errorf(tok.offset, "invalid number %q", tok.text)
+		}
+		return reflect.ValueOf(f), p.advance()
+	case tok.kind == tokenIdent && (strings.EqualFold(tok.text, "true") || strings.EqualFold(tok.text, "false")):
+		return reflect.ValueOf(strings.EqualFold(tok.text, "true")), p.advance()
+	}
+	return reflect.Value{}, p.errorf(tok.offset, "unexpected %v, expect string, number, true or false", tok)
+}
+
+func addKeyCondition(filter *Filter, op string, value []byte) {
+	switch op {
+	case ">=":
+		if bytes.Compare(value, filter.min) > 0 {
+			filter.min = value
+		}
+		return
+	case ">":
+		// seek to value and skip it, the keys before it are never visited
+		if bytes.Compare(value, filter.min) > 0 {
+			filter.min = value
+		}
+		filter.AddCondition(func(k, v []byte) (skip bool, stop bool) {
+			return bytes.Equal(k, value), false
+		})
+		return
+	case "<":
+		// the keys are sorted, so none of the rest is less than value
+		filter.AddCondition(func(k, v []byte) (skip bool, stop bool) {
+			if bytes.Compare(k, value) >= 0 {
+				return true, true
+			}
+			return false, false
+		})
+		return
+	case "<=":
+		if len(filter.max) == 0 || bytes.Compare(value, filter.max) < 0 {
+			filter.max = value
+		}
+		return
+	}
+	filter.AddCondition(func(k, v []byte) (skip bool, stop bool) {
+		return !matchOperator(op, bytes.Compare(k, value)), false
+	})
+}
+
+func fieldCondition(field, op string, value reflect.Value) func(obj Storable) (skip bool, stop bool) {
+	return func(obj Storable) (skip bool, stop bool) {
+		v, ok := fieldByName(obj, field)
+		if !ok {
+			return true, false
+		}
+		ret, ok := compareValues(v, value)
+		if !ok {
+			return true, false
+		}
+		return !matchOperator(op, ret), false
+	}
+}
+
+func matchOperator(op string, ret int) bool {
+	switch op {
+	case "=":
+		return ret == 0
+	case "!=":
+		return ret != 0
+	case "<":
+		return ret < 0
+	case "<=":
+		return ret <= 0
+	case ">":
+		return ret > 0
+	case ">=":
+		return ret >= 0
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package boltutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	db := testDB(t, true)
+	defer db.Close()
+	require.NoError(t, db.MPut(
+		&Person{Id: "user/alice", Name: "Alice", Age: 17},
+		&Person{Id: "user/bob", Name: "Bob", Age: 18},
+		&Person{Id: "user/carol", Name: "Carol", Age: 30},
+		&Person{Id: "admin/dave", Name: "Dave", Age: 40},
+	))
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "empty",
+			query: "",
+			want:  []string{"admin/dave", "user/alice", "user/bob", "user/carol"},
+		},
+		{
+			name:  "prefix",
+			query: `prefix:"user/"`,
+			want:  []string{"user/alice", "user/bob", "user/carol"},
+		},
+		{
+			name:  "prefix and field",
+			query: `prefix:"user/" and Age >= 18`,
+			want:  []string{"user/bob", "user/carol"},
+		},
+		{
+			name:  "limit",
+			query: `prefix:"user/" AND Age >= 18 LIMIT 1`,
+			want:  []string{"user/bob"},
+		},
+		{
+			name:  "only limit",
+			query: `limit 2`,
+			want:  []string{"admin/dave", "user/alice"},
+		},
+		{
+			name:  "string field",
+			query: "Name != `Bob` and Name < \"Dave\"",
+			want:  []string{"user/alice", "user/carol"},
+		},
+		{
+			name:  "key range",
+			query: `key >= "user/b" and key <= "user/c"`,
+			want:  []string{"user/bob"},
+		},
+		{
+			name:  "key exclusive range",
+			query: `key > "user/alice" and key < "user/carol"`,
+			want:  []string{"user/bob"},
+		},
+		{
+			name:  "key equal",
+			query: `key == "user/carol"`,
+			want:  []string{"user/carol"},
+		},
+		{
+			name:  "float",
+			query: `Age > 17.5 and Age < 3e1`,
+			want:  []string{"user/bob"},
+		},
+		{
+			name:  "unknown field",
+			query: `Unknown = 1`,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.query)
+			require.NoError(t, err)
+
+			var persons []*Person
+			require.NoError(t, db.Scan(&persons, filter))
+			var got []string
+			for _, v := range persons {
+				got = append(got, v.Id)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseFilter_KeyBounds(t *testing.T) {
+	filter, err := ParseFilter(`key > "b" and key < "d"`)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("b"), filter.seek())
+
+	check := func(k string, wantSkip, wantStop bool) {
+		var skip, stop bool
+		for _, c := range filter.getConditions() {
+			s, st := c([]byte(k), nil)
+			skip, stop = skip || s, stop || st
+		}
+		assert.Equal(t, wantSkip, skip, k)
+		assert.Equal(t, wantStop, stop, k)
+	}
+	check("b", true, false)
+	check("c", false, false)
+	check("d", true, true)
+	check("e", true, true)
+}
+
+func TestParseFilter_Error(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantOffset int
+	}{
+		{name: "missing operator", query: `Age 18`, wantOffset: 4},
+		{name: "missing value", query: `Age >=`, wantOffset: 6},
+		{name: "unterminated string", query: `prefix:"user`, wantOffset: 7},
+		{name: "prefix without colon", query: `prefix "user"`, wantOffset: 7},
+		{name: "prefix with number", query: `prefix:1`, wantOffset: 7},
+		{name: "missing and", query: `Age > 1 Age < 2`, wantOffset: 8},
+		{name: "invalid limit", query: `limit -1`, wantOffset: 6},
+		{name: "invalid number", query: `Age > 1.2.3`, wantOffset: 6},
+		{name: "unexpected character", query: `Age ~ 1`, wantOffset: 4},
+		{name: "single bang", query: `Age ! 1`, wantOffset: 4},
+		{name: "trailing and", query: `Age > 1 and`, wantOffset: 11},
+		{name: "keyword as field", query: `and > 1`, wantOffset: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFilter(tt.query)
+			var pe *ParseError
+			require.True(t, errors.As(err, &pe), "got %v", err)
+			assert.Equal(t, tt.wantOffset, pe.Offset, err.Error())
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package boltutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// tombstoneBucket is the bucket of the soft deleted records of all buckets, see tombstoneKey.
+var tombstoneBucket = []byte("boltutil_tombstone")
+
+// tombstoneKey returns the key of the soft deleted record in the tombstone bucket, which is the uvarint length
+// of the bucket name followed by the bucket name and the key, so the records of a bucket share a prefix.
+func tombstoneKey(bucket, key []byte) []byte {
+	ret := binary.AppendUvarint(nil, uint64(len(bucket)))
+	ret = append(ret, bucket...)
+	return append(ret, key...)
+}
+
+// splitTombstoneKey splits the key in the tombstone bucket into the bucket name and the key.
+func splitTombstoneKey(k []byte) (bucket, key []byte, err error) {
+	n, size := binary.Uvarint(k)
+	if size <= 0 || uint64(len(k)-size) < n {
+		return nil, nil, fmt.Errorf("invalid tombstone key: %q", k)
+	}
+	return k[size : size+int(n)], k[size+int(n):], nil
+}
+
+// tombstone copies the record of the key in the bucket into the tombstone bucket with the time of deletion,
+// it does nothing if the record does not exist.
+func tombstone(tx kvTx, bucket kvBucket, name, key []byte) error {
+	value := bucket.Get(key)
+	if value == nil {
+		return nil
+	}
+	tombstones, err := tx.createBucketIfNotExists(tombstoneBucket)
+	if err != nil {
+		return err
+	}
+	return tombstones.Put(tombstoneKey(name, key), encodeTombstone(time.Now(), value))
+}
+
+// encodeTombstone encodes the time of deletion as a big-endian int64 of unix nanoseconds, followed by the value.
+func encodeTombstone(deletedAt time.Time, value []byte) []byte {
+	ret := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(ret, uint64(deletedAt.UnixNano()))
+	copy(ret[8:], value)
+	return ret
+}
+
+func decodeTombstone(data []byte) (time.Time, []byte, error) {
+	if len(data) < 8 {
+		return time.Time{}, nil, fmt.Errorf("invalid tombstone: %d bytes", len(data))
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(data))), data[8:], nil
+}
+
+// Restore moves the soft deleted record of obj back to its bucket and injects it into obj.
+// It fails with ErrKeyNotExist if there is no such deleted record, or ErrAlreadyExist if the key has been used again.
+func (d *DB) Restore(obj Storable) error {
+	return d.RestoreContext(context.Background(), obj)
+}
+
+// RestoreContext is like Restore but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) RestoreContext(ctx context.Context, obj Storable) (err error) {
+	op := d.newOperation(ctx, "restore", obj.BoltBucket(), obj.BoltKey())
+	defer op.finish(&err)
+
+	return d.update(ctx, func(tx *bbolt.Tx) error {
+		return d.restore(op, boltTx{tx}, obj)
+	})
+}
+
+// restore moves the soft deleted record of obj back to its bucket.
+func (d *DB) restore(op *operation, tx kvTx, obj Storable) error {
+	tombstones := tx.bucket(tombstoneBucket)
+	if tombstones == nil {
+		return ErrKeyNotExist
+	}
+	k := tombstoneKey(obj.BoltBucket(), obj.BoltKey())
+	data := tombstones.Get(k)
+	if data == nil {
+		return ErrKeyNotExist
+	}
+	_, value, err := decodeTombstone(data)
+	if err != nil {
+		return err
+	}
+	value = bytes.Clone(value)
+
+	bucket, err := tx.createBucketIfNotExists(obj.BoltBucket())
+	if err != nil {
+		return err
+	}
+	if bucket.Get(obj.BoltKey()) != nil {
+		return ErrAlreadyExist
+	}
+	if err := bucket.Put(obj.BoltKey(), value); err != nil {
+		return err
+	}
+	d.invalidate(bucket.Tx(), obj.BoltBucket(), obj.BoltKey())
+	if err := d.audit(op, tx, obj.BoltBucket(), obj.BoltKey(), nil, value); err != nil {
+		return err
+	}
+	if err := d.decode(op, obj.BoltKey(), value, obj); err != nil {
+		return err
+	}
+	return tombstones.Delete(k)
+}
+
+// PurgeDeleted removes the soft deleted records of all types which were deleted before the time,
+// returns the count of removed records.
+func (d *DB) PurgeDeleted(before time.Time) (int, error) {
+	return d.PurgeDeletedContext(context.Background(), before)
+}
+
+// PurgeDeletedContext is like PurgeDeleted but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) PurgeDeletedContext(ctx context.Context, before time.Time) (_ int, err error) {
+	op := d.newOperation(ctx, "purge deleted", tombstoneBucket, nil)
+	defer op.finish(&err)
+
+	count := 0
+	if err := d.update(ctx, func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(tombstoneBucket)
+		if bucket == nil {
+			return nil
+		}
+		var err error
+		count, err = d.purgeDeleted(op, bucket, bucket.Cursor(), before)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// purgeDeleted removes the records deleted before the time from the tombstone bucket, cur is the cursor of the bucket.
+func (d *DB) purgeDeleted(op *operation, bucket kvBucket, cur cursor, before time.Time) (int, error) {
+	var keys [][]byte
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		if err := op.ctx.Err(); err != nil {
+			return 0, err
+		}
+		deletedAt, _, err := decodeTombstone(v)
+		if err != nil {
+			return 0, &OpError{
+				Bucket: tombstoneBucket,
+				Key:    bytes.Clone(k),
+				Err:    err,
+			}
+		}
+		if deletedAt.Before(before) {
+			keys = append(keys, bytes.Clone(k))
+		}
+	}
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
@@ -0,0 +1,98 @@
+package boltutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestDB_SoftDelete(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	require.NoError(t, db.MPut(&Note{Id: "a", Text: "apple"}, &Note{Id: "b", Text: "banana"}, &Note{Id: "c", Text: "cherry"}))
+	require.NoError(t, db.Delete(&Note{Id: "a"}))
+	n, err := db.DeleteWhere(&Note{}, NewFilter().SetPrefix([]byte("b")))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.ErrorIs(t, db.Get(&Note{Id: "a"}), ErrKeyNotExist)
+	var notes []*Note
+	require.NoError(t, db.Scan(&notes))
+	require.Len(t, notes, 1)
+	assert.Equal(t, "cherry", notes[0].Text)
+
+	require.NoError(t, db.Unwrap().View(func(tx *bbolt.Tx) error {
+		tombstones := tx.Bucket(tombstoneBucket)
+		assert.Equal(t, 2, tombstones.Stats().KeyN)
+		deletedAt, value, err := decodeTombstone(tombstones.Get(tombstoneKey([]byte("note"), []byte("a"))))
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), deletedAt, time.Minute)
+		assert.NotEmpty(t, value)
+		return nil
+	}))
+
+	// hard deleted
+	require.NoError(t, db.Delete(&Person{Id: "jason"}))
+	assert.ErrorIs(t, db.Restore(&Person{Id: "jason"}), ErrKeyNotExist)
+}
+
+func TestDB_Restore(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	require.NoError(t, db.Put(&Note{Id: "a", Text: "apple"}))
+	require.NoError(t, db.Delete(&Note{Id: "a"}))
+
+	note := &Note{Id: "a"}
+	require.NoError(t, db.Restore(note))
+	assert.Equal(t, "apple", note.Text)
+	note = &Note{Id: "a"}
+	require.NoError(t, db.Get(note))
+	assert.Equal(t, "apple", note.Text)
+	assert.ErrorIs(t, db.Restore(&Note{Id: "a"}), ErrKeyNotExist)
+	assert.ErrorIs(t, db.Restore(&Note{Id: "z"}), ErrKeyNotExist)
+
+	require.NoError(t, db.Delete(&Note{Id: "a"}))
+	require.NoError(t, db.Put(&Note{Id: "a", Text: "avocado"}))
+	err := db.Restore(&Note{Id: "a"})
+	assert.ErrorIs(t, err, ErrAlreadyExist)
+	assert.ErrorContains(t, err, `restore bucket "note" key "a"`)
+}
+
+func TestDB_PurgeDeleted(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	n, err := db.PurgeDeleted(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	require.NoError(t, db.MPut(&Note{Id: "a", Text: "apple"}, &Note{Id: "b", Text: "banana"}))
+	require.NoError(t, db.Delete(&Note{Id: "a"}))
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+	require.NoError(t, db.Delete(&Note{Id: "b"}))
+
+	n, err = db.PurgeDeleted(before)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.ErrorIs(t, db.Restore(&Note{Id: "a"}), ErrKeyNotExist)
+	require.NoError(t, db.Restore(&Note{Id: "b"}))
+}
+
+func TestTombstoneKey(t *testing.T) {
+	k := tombstoneKey([]byte("note"), []byte("a"))
+	bucket, key, err := splitTombstoneKey(k)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("note"), bucket)
+	assert.Equal(t, []byte("a"), key)
+
+	_, _, err = splitTombstoneKey([]byte{10, 'a'})
+	assert.Error(t, err)
+	_, _, err = splitTombstoneKey(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package boltutil
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// sorter collects objects and sorts them,
+// it keeps only the top limit objects with a bounded heap if limit is greater than 0.
+type sorter struct {
+	less  func(a, b Storable) bool
+	limit int
+	items []sortItem
+	seq   int
+}
+
+type sortItem struct {
+	obj Storable
+	seq int // the order of the object in the bucket, to keep sorting stable
+}
+
+func newSorter(less func(a, b Storable) bool, limit int) *sorter {
+	return &sorter{
+		less:  less,
+		limit: limit,
+	}
+}
+
+func (s *sorter) itemLess(a, b sortItem) bool {
+	if s.less(a.obj, b.obj) {
+		return true
+	}
+	if s.less(b.obj, a.obj) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+// Push adds obj into the sorter, it reports whether obj is kept.
+func (s *sorter) Push(obj Storable) bool {
+	item := sortItem{
+		obj: obj,
+		seq: s.seq,
+	}
+	s.seq++
+
+	if s.limit <= 0 {
+		s.items = append(s.items, item)
+		return true
+	}
+	if len(s.items) < s.limit {
+		heap.Push((*sortHeap)(s), item)
+		return true
+	}
+	if !s.itemLess(item, s.items[0]) {
+		return false
+	}
+	s.items[0] = item
+	heap.Fix((*sortHeap)(s), 0)
+	return true
+}
+
+// Result returns the sorted objects.
+func (s *sorter) Result() []Storable {
+	sort.Slice(s.items, func(i, j int) bool {
+		return s.itemLess(s.items[i], s.items[j])
+	})
+	ret := make([]Storable, 0, len(s.items))
+	for _, v := range s.items {
+		ret = append(ret, v.obj)
+	}
+	return ret
+}
+
+// sortHeap is a max-heap of the sorter's items, the top is the one that should be dropped first.
+type sortHeap sorter
+
+func (h *sortHeap) Len() int {
+	return len(h.items)
+}
+
+func (h *sortHeap) Less(i, j int) bool {
+	return (*sorter)(h).itemLess(h.items[j], h.items[i])
+}
+
+func (h *sortHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+}
+
+func (h *sortHeap) Push(x any) {
+	h.items = append(h.items, x.(sortItem))
+}
+
+func (h *sortHeap) Pop() any {
+	n := len(h.items)
+	ret := h.items[n-1]
+	h.items = h.items[:n-1]
+	return ret
+}
+
+func fieldLess(field string, desc bool) func(a, b Storable) bool {
+	return func(a, b Storable) bool {
+		x, okX := fieldByName(a, field)
+		y, okY := fieldByName(b, field)
+		if !okX || !okY {
+			return okX && !okY
+		}
+		ret, ok := compareValues(x, y)
+		if !ok {
+			return false
+		}
+		if desc {
+			return ret > 0
+		}
+		return ret < 0
+	}
+}
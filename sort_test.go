@@ -0,0 +1,109 @@
+package boltutil
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_SortBy(t *testing.T) {
+	db := testDB(t, true)
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	var cars []Storable
+	for _, i := range rand.Perm(20) {
+		cars = append(cars, &Car{
+			Name:      "car",
+			CreatedAt: now.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	require.NoError(t, db.MPut(cars...))
+
+	createdAts := func(cars []*Car) []time.Time {
+		var ret []time.Time
+		for _, v := range cars {
+			ret = append(ret, v.CreatedAt.UTC())
+		}
+		return ret
+	}
+
+	t.Run("all", func(t *testing.T) {
+		var got []*Car
+		require.NoError(t, db.Scan(&got, NewFilter().SortBy("CreatedAt")))
+		require.Len(t, got, 20)
+		assert.True(t, sort.SliceIsSorted(got, func(i, j int) bool {
+			return got[i].CreatedAt.Before(got[j].CreatedAt)
+		}))
+	})
+
+	t.Run("top k", func(t *testing.T) {
+		var got []*Car
+		require.NoError(t, db.Scan(&got, NewFilter().SortBy("CreatedAt", true).SetLimit(3)))
+		assert.Equal(t, []time.Time{
+			now.Add(19 * time.Minute),
+			now.Add(18 * time.Minute),
+			now.Add(17 * time.Minute),
+		}, createdAts(got))
+	})
+
+	t.Run("first", func(t *testing.T) {
+		got := &Car{}
+		require.NoError(t, db.First(got, NewFilter().SortBy("CreatedAt", true)))
+		assert.Equal(t, now.Add(19*time.Minute), got.CreatedAt.UTC())
+	})
+
+	t.Run("first not exist", func(t *testing.T) {
+		got := &Car{}
+		assert.ErrorIs(t, db.First(got, NewFilter().SortBy("CreatedAt").AddCondition(func(k, v []byte) (bool, bool) {
+			return true, false
+		})), ErrNotExist)
+	})
+
+	t.Run("count", func(t *testing.T) {
+		got, err := db.Count(&Car{}, NewFilter().SortBy("CreatedAt").SetLimit(5))
+		require.NoError(t, err)
+		assert.Equal(t, 5, got)
+	})
+}
+
+func TestFilter_SortFunc(t *testing.T) {
+	db := testDB(t, true)
+	defer db.Close()
+	require.NoError(t, db.MPut(
+		&Person{Id: "a", Name: "Alice", Age: 30},
+		&Person{Id: "b", Name: "Bob", Age: 20},
+		&Person{Id: "c", Name: "Carol", Age: 30},
+		&Person{Id: "d", Name: "Dave", Age: 10},
+	))
+
+	ids := func(persons []*Person) []string {
+		var ret []string
+		for _, v := range persons {
+			ret = append(ret, v.Id)
+		}
+		return ret
+	}
+
+	var got []*Person
+	require.NoError(t, db.Scan(&got, NewFilter().SortFunc(func(a, b Storable) bool {
+		return a.(*Person).Age > b.(*Person).Age
+	})))
+	assert.Equal(t, []string{"a", "c", "b", "d"}, ids(got))
+
+	got = nil
+	require.NoError(t, db.Scan(&got, NewFilter().SortBy("Age").SetLimit(2)))
+	assert.Equal(t, []string{"d", "b"}, ids(got))
+
+	got = nil
+	require.NoError(t, db.Scan(&got, NewFilter().SortBy("Age", true).SetLimit(2)))
+	assert.Equal(t, []string{"a", "c"}, ids(got))
+
+	got = nil
+	require.NoError(t, db.Scan(&got, NewFilter().SortBy("Unknown").SetLimit(2)))
+	assert.Equal(t, []string{"a", "b"}, ids(got))
+}
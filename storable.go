@@ -1,5 +1,7 @@
 package boltutil
 
+import "go.etcd.io/bbolt"
+
 // Storable is the interface that can be stored into bolt.
 type Storable interface {
 	HasBucket
@@ -16,7 +18,52 @@ type HasCoder interface {
 	BoltCoder() Coder
 }
 
-// HasBeforePut is the interface that indicates the BeforePut method
+// HasKeyDecoder is the interface that indicates the KeyDecoder of the type, which is used by ScanMap
+type HasKeyDecoder interface {
+	BoltKeyDecoder() KeyDecoder
+}
+
+// HasBeforePut is the interface that indicates the BeforePut method.
+// It is called on every put, even if the object already exists, so it consumes an id each time,
+// use HasBeforeInsert and HasBeforeUpdate to tell insert from update.
 type HasBeforePut interface {
 	BeforePut(id uint64) // will be called before put, id is an auto incrementing integer for the bucket
 }
+
+// HasBeforeInsert is the interface that indicates the BeforeInsert method
+type HasBeforeInsert interface {
+	BeforeInsert(id uint64) // will be called before put if the key does not exist, id is an auto incrementing integer for the bucket
+}
+
+// HasBeforeUpdate is the interface that indicates the BeforeUpdate method
+type HasBeforeUpdate interface {
+	BeforeUpdate() // will be called before put if the key already exists
+}
+
+// Validator is the interface that indicates the Validate method,
+// an object failing validation is not stored and the error wraps ErrInvalid.
+type Validator interface {
+	Validate() error // will be called before encoding in put
+}
+
+// HasAfterPut is the interface that indicates the AfterPut method
+type HasAfterPut interface {
+	AfterPut(tx *bbolt.Tx) error // will be called after put in the same transaction, an error aborts the transaction
+}
+
+// HasAfterGet is the interface that indicates the AfterGet method
+type HasAfterGet interface {
+	AfterGet() error // will be called after the object is decoded
+}
+
+// HasBeforeDelete is the interface that indicates the BeforeDelete method
+type HasBeforeDelete interface {
+	BeforeDelete(tx *bbolt.Tx) error // will be called before delete in the same transaction, an error vetoes the delete
+}
+
+// HasSoftDelete is the interface that indicates whether the type is soft deleted.
+// The soft deleted records are moved into a tombstone bucket with the time of deletion,
+// they are invisible to the other operations, and can be restored with Restore or removed with PurgeDeleted.
+type HasSoftDelete interface {
+	BoltSoftDelete() bool // records are soft deleted if it returns true
+}
@@ -2,9 +2,13 @@ package boltutil
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
+
+	"go.etcd.io/bbolt"
 )
 
 type Person struct {
@@ -57,3 +61,95 @@ func (c *Wind) BoltBucket() []byte {
 func (c *Wind) BoltKey() []byte {
 	return []byte(fmt.Sprintf("%v %v", time.Now(), rand.Int()))
 }
+
+type Book struct {
+	Id    string
+	Title string
+	Keep  bool
+}
+
+func (b *Book) BoltBucket() []byte {
+	return []byte("book")
+}
+
+func (b *Book) BoltKey() []byte {
+	return []byte(b.Id)
+}
+
+func (b *Book) Validate() error {
+	if b.Title == "" {
+		return errors.New("empty title")
+	}
+	return nil
+}
+
+func (b *Book) AfterGet() error {
+	b.Title = strings.TrimSpace(b.Title)
+	return nil
+}
+
+// AfterPut indexes the book by title
+func (b *Book) AfterPut(tx *bbolt.Tx) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte("book_title"))
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(b.Title), b.BoltKey())
+}
+
+// BeforeDelete vetoes deleting kept books and removes the title index
+func (b *Book) BeforeDelete(tx *bbolt.Tx) error {
+	if b.Keep {
+		return errors.New("book is kept")
+	}
+	if bucket := tx.Bucket([]byte("book_title")); bucket != nil && b.Title != "" {
+		return bucket.Delete([]byte(b.Title))
+	}
+	return nil
+}
+
+type Ticket struct {
+	Id        uint64
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (t *Ticket) BoltBucket() []byte {
+	return []byte("ticket")
+}
+
+func (t *Ticket) BoltKey() []byte {
+	ret := make([]byte, 8)
+	binary.BigEndian.PutUint64(ret, t.Id)
+	return ret
+}
+
+func (t *Ticket) BeforeInsert(id uint64) {
+	if t.Id == 0 {
+		t.Id = id
+	}
+	t.CreatedAt = time.Now()
+	t.UpdatedAt = t.CreatedAt
+}
+
+func (t *Ticket) BeforeUpdate() {
+	t.UpdatedAt = time.Now()
+}
+
+type Note struct {
+	Id   string
+	Text string
+}
+
+func (n *Note) BoltBucket() []byte {
+	return []byte("note")
+}
+
+func (n *Note) BoltKey() []byte {
+	return []byte(n.Id)
+}
+
+func (n *Note) BoltSoftDelete() bool {
+	return true
+}
@@ -0,0 +1,91 @@
+package boltutil
+
+import (
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Store is the interface of the common operations of DB, it is implemented by DB and MemDB,
+// so the code depending on Store can be tested with MemDB instead of database files,
+// unless it stores types implementing HasAfterPut or HasBeforeDelete, which MemDB does not support.
+type Store interface {
+	Get(obj Storable, conditions ...*Condition) error
+	Put(obj Storable, conditions ...*Condition) error
+	Delete(obj Storable, conditions ...*Condition) error
+	MGet(objs ...Storable) error
+	MPut(objs ...Storable) error
+	MDelete(objs ...Storable) error
+	Scan(result any, filters ...*Filter) error
+	First(obj Storable, filters ...*Filter) error
+	Count(obj Storable, filters ...*Filter) (int, error)
+	Exist(obj Storable) (bool, error)
+	DeleteBucket(hasBuckets ...HasBucket) error
+	DeleteAllBucket() error
+}
+
+// SoftDeleteStore is the interface of the operations on the soft deleted records, see HasSoftDelete.
+// It is implemented by DB and MemDB.
+type SoftDeleteStore interface {
+	Restore(obj Storable) error
+	PurgeDeleted(before time.Time) (int, error)
+}
+
+// AuditStore is the interface of the queries of the audit log, see WithAuditBucket.
+// It is implemented by DB and MemDB.
+type AuditStore interface {
+	AuditByKey(obj Storable) ([]*AuditEntry, error)
+	AuditBetween(from, to time.Time) ([]*AuditEntry, error)
+}
+
+var (
+	_ Store           = (*DB)(nil)
+	_ Store           = (*MemDB)(nil)
+	_ SoftDeleteStore = (*DB)(nil)
+	_ SoftDeleteStore = (*MemDB)(nil)
+	_ AuditStore      = (*DB)(nil)
+	_ AuditStore      = (*MemDB)(nil)
+)
+
+// cursor is the subset of *bbolt.Cursor used by the operations, it is implemented by MemDB too.
+type cursor interface {
+	First() (key []byte, value []byte)
+	Seek(seek []byte) (key []byte, value []byte)
+	Next() (key []byte, value []byte)
+}
+
+// kvBucket is the subset of *bbolt.Bucket used by the operations, it is implemented by MemDB too.
+// Tx returns nil if the bucket is not in a bbolt transaction, then the hooks which need a transaction are unsupported.
+type kvBucket interface {
+	Get(key []byte) []byte
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	NextSequence() (uint64, error)
+	Tx() *bbolt.Tx
+}
+
+// kvTx is the subset of *bbolt.Tx used by the operations, it is implemented by MemDB too.
+type kvTx interface {
+	bucket(name []byte) kvBucket // nil if the bucket does not exist
+	createBucketIfNotExists(name []byte) (kvBucket, error)
+}
+
+// boltTx implements kvTx with a *bbolt.Tx.
+type boltTx struct {
+	tx *bbolt.Tx
+}
+
+func (t boltTx) bucket(name []byte) kvBucket {
+	if bucket := t.tx.Bucket(name); bucket != nil {
+		return bucket
+	}
+	return nil
+}
+
+func (t boltTx) createBucketIfNotExists(name []byte) (kvBucket, error) {
+	bucket, err := t.tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, err
+	}
+	return bucket, nil
+}
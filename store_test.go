@@ -0,0 +1,396 @@
+package boltutil
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestStore(t *testing.T) {
+	stores := []struct {
+		name     string
+		newStore func(t *testing.T, options ...Option) Store
+	}{
+		{
+			name: "DB",
+			newStore: func(t *testing.T, options ...Option) Store {
+				db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), options...)
+				require.NoError(t, err)
+				t.Cleanup(func() {
+					_ = db.Close()
+				})
+				return db
+			},
+		},
+		{
+			name: "MemDB",
+			newStore: func(t *testing.T, options ...Option) Store {
+				return NewMemDB(options...)
+			},
+		},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			testStore(t, tt.newStore)
+		})
+	}
+}
+
+// testStore is the conformance test suite which every implementation of Store should pass,
+// it covers the types without HasAfterPut and HasBeforeDelete, which MemDB does not support.
+func testStore(t *testing.T, newStore func(t *testing.T, options ...Option) Store) {
+	seed := func(t *testing.T) Store {
+		s := newStore(t)
+		require.NoError(t, s.MPut(
+			&Person{Id: "jason", Name: "Jason Song", Age: 25},
+			&Person{Id: "vivia", Name: "Vivia Lei", Age: 24},
+			&Person{Id: "jack", Name: "Jack Ma", Age: 30},
+			&Person{Id: "tom", Name: "Tom Li", Age: 18},
+		))
+		return s
+	}
+	ids := func(persons []*Person) []string {
+		var ret []string
+		for _, v := range persons {
+			ret = append(ret, v.Id)
+		}
+		return ret
+	}
+
+	t.Run("get", func(t *testing.T) {
+		s := newStore(t)
+		err := s.Get(&Person{Id: "jason"})
+		assert.ErrorIs(t, err, ErrBucketNotExist)
+		assert.EqualError(t, err, `get bucket "person" key "jason": bucket not exist`)
+		assert.NoError(t, s.Get(&Person{Id: "jason"}, NewCondition().IgnoreIfNotExist()))
+
+		s = seed(t)
+		got := &Person{Id: "jason"}
+		require.NoError(t, s.Get(got))
+		assert.Equal(t, &Person{Id: "jason", Name: "Jason Song", Age: 25}, got)
+
+		err = s.Get(&Person{Id: "lily"})
+		assert.ErrorIs(t, err, ErrKeyNotExist)
+		assert.EqualError(t, err, `get bucket "person" key "lily": key not exist`)
+		assert.NoError(t, s.Get(&Person{Id: "lily"}, NewCondition().IgnoreIfNotExist()))
+		assert.ErrorIs(t, s.Get(&Person{Id: "lily"}, NewCondition(), NewCondition()), ErrTooManyConditions)
+	})
+
+	t.Run("put with conditions", func(t *testing.T) {
+		s := newStore(t)
+		assert.ErrorIs(t, s.Put(&Person{Id: "jason"}, NewCondition().FailIfNotExist()), ErrBucketNotExist)
+
+		s = seed(t)
+		assert.ErrorIs(t, s.Put(&Person{Id: "lily"}, NewCondition().FailIfNotExist()), ErrKeyNotExist)
+		err := s.Put(&Person{Id: "jason", Name: "changed"}, NewCondition().FailIfExist())
+		assert.ErrorIs(t, err, ErrAlreadyExist)
+		assert.EqualError(t, err, `put bucket "person" key "jason": already exist`)
+		require.NoError(t, s.Put(&Person{Id: "jason", Name: "changed"}, NewCondition().IgnoreIfExist()))
+		got := &Person{Id: "jason"}
+		require.NoError(t, s.Get(got))
+		assert.Equal(t, "Jason Song", got.Name)
+
+		require.NoError(t, s.Put(&Person{Id: "jason", Name: "changed"}, NewCondition().FailIfNotExist()))
+		got = &Person{Id: "jason"}
+		require.NoError(t, s.Get(got))
+		assert.Equal(t, "changed", got.Name)
+
+		assert.ErrorIs(t, s.Put(&Person{}), bbolt.ErrKeyRequired)
+	})
+
+	t.Run("delete with conditions", func(t *testing.T) {
+		s := newStore(t)
+		assert.NoError(t, s.Delete(&Person{Id: "jason"}))
+		assert.ErrorIs(t, s.Delete(&Person{Id: "jason"}, NewCondition().FailIfNotExist()), ErrBucketNotExist)
+
+		s = seed(t)
+		assert.NoError(t, s.Delete(&Person{Id: "lily"}))
+		assert.ErrorIs(t, s.Delete(&Person{Id: "lily"}, NewCondition().FailIfNotExist()), ErrKeyNotExist)
+
+		deleted := &Person{Id: "jason"}
+		require.NoError(t, s.Delete(deleted, NewCondition().LoadDeleted()))
+		assert.Equal(t, "Jason Song", deleted.Name)
+		exist, err := s.Exist(&Person{Id: "jason"})
+		require.NoError(t, err)
+		assert.False(t, exist)
+	})
+
+	t.Run("mget", func(t *testing.T) {
+		s := seed(t)
+		jason, vivia := &Person{Id: "jason"}, &Person{Id: "vivia"}
+		require.NoError(t, s.MGet(jason, vivia))
+		assert.Equal(t, "Jason Song", jason.Name)
+		assert.Equal(t, "Vivia Lei", vivia.Name)
+
+		err := s.MGet(jason, &Person{Id: "lily"})
+		assert.ErrorIs(t, err, ErrKeyNotExist)
+		assert.EqualError(t, err, `mget bucket "person" key "lily": key not exist`)
+		assert.ErrorIs(t, s.MGet(jason, &Car{Id: 1}), ErrBucketNotExist)
+	})
+
+	t.Run("all or nothing", func(t *testing.T) {
+		s := seed(t)
+		err := s.MPut(&Person{Id: "lily"}, &Ticket{Title: "first"}, &Person{})
+		assert.ErrorIs(t, err, bbolt.ErrKeyRequired)
+		exist, err := s.Exist(&Person{Id: "lily"})
+		require.NoError(t, err)
+		assert.False(t, exist)
+		var tickets []*Ticket
+		require.NoError(t, s.Scan(&tickets))
+		assert.Empty(t, tickets)
+
+		require.NoError(t, s.MDelete(&Person{Id: "jason"}, &Person{Id: "lily"}))
+		count, err := s.Count(&Person{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("hooks and coders", func(t *testing.T) {
+		s := newStore(t)
+		require.NoError(t, s.MPut(&Car{Name: "tesla"}, &Car{Name: "byd"}, &Car{Id: 100, Name: "nio"}))
+		var cars []*Car
+		require.NoError(t, s.Scan(&cars))
+		require.Len(t, cars, 3)
+		assert.Equal(t, []uint32{1, 2, 100}, []uint32{cars[0].Id, cars[1].Id, cars[2].Id})
+
+		first := &Ticket{Title: "first"}
+		require.NoError(t, s.Put(first))
+		assert.Equal(t, uint64(1), first.Id)
+		createdAt := first.CreatedAt
+		first.Title = "updated"
+		require.NoError(t, s.Put(first))
+		got := &Ticket{Id: 1}
+		require.NoError(t, s.Get(got))
+		assert.Equal(t, "updated", got.Title)
+		assert.True(t, got.CreatedAt.Equal(createdAt))
+		assert.True(t, got.UpdatedAt.After(createdAt))
+
+		second := &Ticket{Title: "second"}
+		require.NoError(t, s.Put(second))
+		assert.Equal(t, uint64(2), second.Id)
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		s := newStore(t)
+		var persons []*Person
+		require.NoError(t, s.Scan(&persons))
+		assert.Empty(t, persons)
+		assert.Error(t, s.Scan(persons))
+		assert.ErrorIs(t, s.Scan(&persons, NewFilter(), NewFilter()), ErrTooManyFilters)
+
+		s = seed(t)
+		require.NoError(t, s.Scan(&persons))
+		assert.Equal(t, []string{"jack", "jason", "tom", "vivia"}, ids(persons))
+
+		tests := []struct {
+			name   string
+			filter *Filter
+			want   []string
+		}{
+			{
+				name:   "prefix",
+				filter: NewFilter().SetPrefix([]byte("ja")),
+				want:   []string{"jack", "jason"},
+			},
+			{
+				name:   "range",
+				filter: NewFilter().SetRange([]byte("jason"), []byte("tom")),
+				want:   []string{"jason", "tom"},
+			},
+			{
+				name:   "limit",
+				filter: NewFilter().SetLimit(3),
+				want:   []string{"jack", "jason", "tom"},
+			},
+			{
+				name: "condition",
+				filter: NewFilter().AddCondition(func(k, v []byte) (skip bool, stop bool) {
+					return string(k) == "jason", string(k) == "tom"
+				}),
+				want: []string{"jack"},
+			},
+			{
+				name: "storable condition",
+				filter: NewFilter().AddStorableCondition(func(obj Storable) (skip bool, stop bool) {
+					return obj.(*Person).Age < 25, false
+				}),
+				want: []string{"jack", "jason"},
+			},
+			{
+				name:   "sort",
+				filter: NewFilter().SortBy("Age", true),
+				want:   []string{"jack", "jason", "vivia", "tom"},
+			},
+			{
+				name:   "sort with limit",
+				filter: NewFilter().SortBy("Age").SetLimit(2),
+				want:   []string{"tom", "vivia"},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var persons []*Person
+				require.NoError(t, s.Scan(&persons, tt.filter))
+				assert.Equal(t, tt.want, ids(persons))
+			})
+		}
+	})
+
+	t.Run("first", func(t *testing.T) {
+		s := seed(t)
+		got := &Person{}
+		require.NoError(t, s.First(got))
+		assert.Equal(t, "jack", got.Id)
+
+		got = &Person{}
+		require.NoError(t, s.First(got, NewFilter().SortBy("Age")))
+		assert.Equal(t, "tom", got.Id)
+
+		assert.ErrorIs(t, s.First(&Person{}, NewFilter().SetPrefix([]byte("lily"))), ErrKeyNotExist)
+		assert.ErrorIs(t, s.First(&Person{}, NewFilter().SetPrefix([]byte("lily")).SortBy("Age")), ErrKeyNotExist)
+	})
+
+	t.Run("count", func(t *testing.T) {
+		s := newStore(t)
+		count, err := s.Count(&Person{})
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		s = seed(t)
+		count, err = s.Count(&Person{}, NewFilter().SetPrefix([]byte("ja")))
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		count, err = s.Count(&Person{}, NewFilter().SortBy("Age").SetLimit(3))
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("decode error", func(t *testing.T) {
+		s := seed(t)
+		var persons []*wrongPerson
+		err := s.Scan(&persons)
+		var codecErr *codecError
+		assert.True(t, errors.As(err, &codecErr))
+		assert.ErrorContains(t, err, `scan bucket "person" key "jack": decode *boltutil.wrongPerson`)
+	})
+
+	t.Run("delete bucket", func(t *testing.T) {
+		s := seed(t)
+		require.NoError(t, s.Put(&Car{Name: "tesla"}))
+		require.NoError(t, s.DeleteBucket(&Person{}, &Wind{}))
+
+		count, err := s.Count(&Person{})
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+		assert.ErrorIs(t, s.Get(&Person{Id: "jason"}), ErrBucketNotExist)
+		exist, err := s.Exist(&Car{Id: 1})
+		require.NoError(t, err)
+		assert.True(t, exist)
+
+		require.NoError(t, s.DeleteAllBucket())
+		exist, err = s.Exist(&Car{Id: 1})
+		require.NoError(t, err)
+		assert.False(t, exist)
+
+		car := &Car{Name: "byd"}
+		require.NoError(t, s.Put(car))
+		assert.Equal(t, uint32(1), car.Id)
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		s := newStore(t)
+		sd := s.(SoftDeleteStore)
+		require.NoError(t, s.MPut(&Note{Id: "a", Text: "apple"}, &Note{Id: "b", Text: "banana"}, &Note{Id: "c", Text: "cherry"}))
+		require.NoError(t, s.Delete(&Note{Id: "a"}))
+		require.NoError(t, s.MDelete(&Note{Id: "b"}, &Note{Id: "z"}))
+
+		assert.ErrorIs(t, s.Get(&Note{Id: "a"}), ErrKeyNotExist)
+		var notes []*Note
+		require.NoError(t, s.Scan(&notes))
+		assert.Equal(t, []*Note{{Id: "c", Text: "cherry"}}, notes)
+
+		note := &Note{Id: "a"}
+		require.NoError(t, sd.Restore(note))
+		assert.Equal(t, "apple", note.Text)
+		require.NoError(t, s.Get(&Note{Id: "a"}))
+		assert.ErrorIs(t, sd.Restore(&Note{Id: "a"}), ErrKeyNotExist)
+		assert.ErrorIs(t, sd.Restore(&Note{Id: "z"}), ErrKeyNotExist)
+
+		require.NoError(t, s.Put(&Note{Id: "b", Text: "blueberry"}))
+		err := sd.Restore(&Note{Id: "b"})
+		assert.ErrorIs(t, err, ErrAlreadyExist)
+		assert.ErrorContains(t, err, `restore bucket "note" key "b"`)
+
+		n, err := sd.PurgeDeleted(time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		require.NoError(t, s.Delete(&Note{Id: "b"}))
+		require.NoError(t, sd.Restore(&Note{Id: "b"}))
+	})
+
+	t.Run("audit", func(t *testing.T) {
+		s := newStore(t, WithAuditBucket([]byte("audit")), WithDefaultCoder(JsonCoder{}))
+		as := s.(AuditStore)
+		_, err := as.AuditByKey(&Person{Id: "jason"})
+		assert.ErrorIs(t, err, ErrBucketNotExist)
+
+		start := time.Now()
+		require.NoError(t, s.Put(&Person{Id: "jason", Name: "Jason"}))
+		require.NoError(t, s.MPut(&Person{Id: "jason", Name: "Jason Song"}, &Person{Id: "jasonx", Name: "Jason X"}))
+		require.NoError(t, s.Delete(&Person{Id: "jason"}))
+		require.NoError(t, s.MDelete(&Person{Id: "jasonx"}, &Person{Id: "nobody"}))
+		assert.Error(t, s.MPut(&Person{Id: "vivia"}, &Person{Id: ""}))
+
+		entries, err := as.AuditByKey(&Person{Id: "jason"})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, uint64(1), entries[0].Seq)
+		assert.Equal(t, "put", entries[0].Op)
+		assert.Equal(t, []byte("person"), entries[0].Bucket)
+		assert.Equal(t, []byte("jason"), entries[0].Key)
+		assert.Nil(t, entries[0].Prev)
+		assert.JSONEq(t, `{"Id":"jason","Name":"Jason","Age":0}`, string(entries[0].New))
+		assert.Equal(t, "mput", entries[1].Op)
+		assert.Equal(t, entries[0].New, entries[1].Prev)
+		assert.Equal(t, "delete", entries[2].Op)
+		assert.Equal(t, entries[1].New, entries[2].Prev)
+		assert.Nil(t, entries[2].New)
+
+		entries, err = as.AuditBetween(start, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, entries, 5)
+		assert.Equal(t, "mdelete", entries[4].Op)
+		assert.Equal(t, []byte("jasonx"), entries[4].Key)
+		entries, err = as.AuditBetween(start, start)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+
+		err = s.DeleteBucket(BucketName("audit"))
+		assert.ErrorIs(t, err, ErrInvalid)
+		assert.ErrorIs(t, s.DeleteBucket(BucketName("audit_index")), ErrInvalid)
+		require.NoError(t, s.DeleteAllBucket())
+		entries, err = as.AuditByKey(&Person{Id: "jason"})
+		require.NoError(t, err)
+		assert.Len(t, entries, 3)
+	})
+}
+
+// wrongPerson is stored in the bucket of Person, but it can not be decoded from Person.
+type wrongPerson struct {
+	Id   string
+	Name int
+}
+
+func (p *wrongPerson) BoltBucket() []byte {
+	return []byte("person")
+}
+
+func (p *wrongPerson) BoltKey() []byte {
+	return []byte(p.Id)
+}
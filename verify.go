@@ -0,0 +1,164 @@
+package boltutil
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+
+	"go.etcd.io/bbolt"
+)
+
+// Report is the result of Verify.
+type Report struct {
+	Errs        []error      // inconsistencies of the database file found by bbolt
+	BadRecords  []*BadRecord // records which can not be decoded
+	Checked     int          // count of records decoded
+	Quarantined bool         // whether the bad records have been moved to the quarantine bucket
+}
+
+// OK reports whether there is no inconsistency or bad record.
+func (r *Report) OK() bool {
+	return len(r.Errs) == 0 && len(r.BadRecords) == 0
+}
+
+// BadRecord is a record which can not be decoded.
+type BadRecord struct {
+	Bucket []byte
+	Key    []byte
+	Err    error
+}
+
+// Verify checks the consistency of the database file with bbolt, and decodes every record in the buckets of the types
+// with their coders, as Get does. The findings are reported, the error is returned only if the verification is aborted.
+func (d *DB) Verify(types ...Storable) (*Report, error) {
+	return d.VerifyContext(context.Background(), types...)
+}
+
+// VerifyContext is like Verify but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) VerifyContext(ctx context.Context, types ...Storable) (_ *Report, err error) {
+	op := d.newOperation(ctx, "verify", nil, nil)
+	defer op.finish(&err)
+
+	tx, err := d.begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	return d.verify(op, tx, types)
+}
+
+// VerifyAndQuarantine verifies the database as Verify, and moves the bad records in a single write transaction
+// into the quarantine bucket, in which there is a nested bucket for every bucket of the bad records.
+// The removals of the bad records are recorded in the audit bucket, see WithAuditBucket.
+func (d *DB) VerifyAndQuarantine(quarantine HasBucket, types ...Storable) (*Report, error) {
+	return d.VerifyAndQuarantineContext(context.Background(), quarantine, types...)
+}
+
+// VerifyAndQuarantineContext is like VerifyAndQuarantine but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) VerifyAndQuarantineContext(ctx context.Context, quarantine HasBucket, types ...Storable) (_ *Report, err error) {
+	op := d.newOperation(ctx, "verify", nil, nil)
+	defer op.finish(&err)
+
+	if err := d.checkAuditBucket(quarantine.BoltBucket()); err != nil {
+		return nil, err
+	}
+	var report *Report
+	if err := d.update(ctx, func(tx *bbolt.Tx) error {
+		var err error
+		if report, err = d.verify(op, tx, types); err != nil {
+			return err
+		}
+		if len(report.BadRecords) == 0 {
+			return nil
+		}
+
+		root, err := tx.CreateBucketIfNotExists(quarantine.BoltBucket())
+		if err != nil {
+			return &OpError{
+				Bucket: quarantine.BoltBucket(),
+				Err:    err,
+			}
+		}
+		for _, v := range report.BadRecords {
+			bucket := tx.Bucket(v.Bucket)
+			dst, err := root.CreateBucketIfNotExists(v.Bucket)
+			if err != nil {
+				return &OpError{
+					Bucket: quarantine.BoltBucket(),
+					Key:    v.Bucket,
+					Err:    err,
+				}
+			}
+			value := bytes.Clone(bucket.Get(v.Key))
+			if err := dst.Put(v.Key, value); err != nil {
+				return &OpError{
+					Bucket: quarantine.BoltBucket(),
+					Key:    v.Key,
+					Err:    err,
+				}
+			}
+			if err := bucket.Delete(v.Key); err != nil {
+				return &OpError{
+					Bucket: v.Bucket,
+					Key:    v.Key,
+					Err:    err,
+				}
+			}
+			if err := d.audit(op, boltTx{tx}, v.Bucket, v.Key, value, nil); err != nil {
+				return &OpError{
+					Bucket: v.Bucket,
+					Key:    v.Key,
+					Err:    err,
+				}
+			}
+			d.invalidate(tx, v.Bucket, v.Key)
+		}
+		report.Quarantined = true
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// verify checks the consistency of tx and decodes the records of the types.
+func (d *DB) verify(op *operation, tx *bbolt.Tx, types []Storable) (*Report, error) {
+	report := &Report{}
+	for err := range tx.Check() {
+		report.Errs = append(report.Errs, err)
+	}
+	if err := op.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, typ := range types {
+		bucket := tx.Bucket(typ.BoltBucket())
+		if bucket == nil {
+			continue
+		}
+		itemType := reflect.TypeOf(typ).Elem()
+		cur := bucket.Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			if err := op.ctx.Err(); err != nil {
+				return nil, err
+			}
+			if v == nil {
+				continue
+			}
+
+			report.Checked++
+			// decode with the coder only, a record failing the hooks like AfterGet is not broken
+			obj := reflect.New(itemType).Interface().(Storable)
+			op.addBytes(len(v))
+			if err := d.getCoder(obj).Decode(bytes.NewReader(v), obj); err != nil {
+				report.BadRecords = append(report.BadRecords, &BadRecord{
+					Bucket: bytes.Clone(typ.BoltBucket()),
+					Key:    bytes.Clone(k),
+					Err:    newCodecError("decode", obj, err),
+				})
+			}
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,109 @@
+package boltutil
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestDB_Verify(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	report, err := db.Verify(&Person{}, &Car{}, &Ticket{})
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Empty(t, report.Errs)
+	assert.Equal(t, 4, report.Checked)
+	require.Len(t, report.BadRecords, 1)
+	assert.Equal(t, []byte("car"), report.BadRecords[0].Bucket)
+	assert.Equal(t, (&Car{Id: 0}).BoltKey(), report.BadRecords[0].Key)
+	assert.ErrorContains(t, report.BadRecords[0].Err, "decode *boltutil.Car")
+	assert.False(t, report.Quarantined)
+
+	report, err = db.Verify(&Person{})
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, 2, report.Checked)
+}
+
+func TestDB_VerifyAndQuarantine(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	report, err := db.VerifyAndQuarantine(BucketName("quarantine"), &Person{}, &Car{})
+	require.NoError(t, err)
+	require.Len(t, report.BadRecords, 1)
+	assert.True(t, report.Quarantined)
+
+	require.NoError(t, db.Unwrap().View(func(tx *bbolt.Tx) error {
+		value := tx.Bucket([]byte("quarantine")).Bucket([]byte("car")).Get((&Car{Id: 0}).BoltKey())
+		assert.Equal(t, []byte("dirty test data, can not be decoded"), value)
+		return nil
+	}))
+	var cars []*Car
+	require.NoError(t, db.Scan(&cars))
+	require.Len(t, cars, 1)
+	assert.Equal(t, "tesla", cars[0].Name)
+
+	report, err = db.VerifyAndQuarantine(BucketName("quarantine"), &Person{}, &Car{})
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.False(t, report.Quarantined)
+}
+
+// hookedPerson is stored in the bucket of Person, its AfterGet always fails.
+type hookedPerson struct {
+	Id   string
+	Name string
+	Age  int
+}
+
+func (p *hookedPerson) BoltBucket() []byte {
+	return []byte("person")
+}
+
+func (p *hookedPerson) BoltKey() []byte {
+	return []byte(p.Id)
+}
+
+func (p *hookedPerson) AfterGet() error {
+	return errors.New("after get failed")
+}
+
+func TestDB_Verify_AfterGet(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	assert.ErrorContains(t, db.Get(&hookedPerson{Id: "jason"}), "after get failed")
+
+	report, err := db.VerifyAndQuarantine(BucketName("quarantine"), &hookedPerson{})
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, 2, report.Checked)
+	assert.False(t, report.Quarantined)
+	require.NoError(t, db.Get(&Person{Id: "jason"}))
+}
+
+func TestDB_VerifyAndQuarantine_Audit(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bolt.db"), WithAuditBucket([]byte("audit")))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Put(&Car{Id: 1, Name: "tesla"}))
+	require.NoError(t, db.Unwrap().Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("car")).Put((&Car{Id: 0}).BoltKey(), []byte("dirty"))
+	}))
+
+	_, err = db.VerifyAndQuarantine(BucketName("quarantine"), &Car{})
+	require.NoError(t, err)
+
+	entries, err := db.AuditByKey(&Car{Id: 0})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "verify", entries[0].Op)
+	assert.Equal(t, []byte("dirty"), entries[0].Prev)
+	assert.Nil(t, entries[0].New)
+}
@@ -0,0 +1,132 @@
+package boltutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+
+	"go.etcd.io/bbolt"
+)
+
+// DeleteWhere deletes the records in the bucket of obj which match the filter, returns the count of deleted records.
+// The records are selected and deleted in one write transaction, or in batches if the filter has a batch size.
+// If obj implements HasBeforeDelete, the records are decoded to call BeforeDelete.
+func (d *DB) DeleteWhere(obj Storable, filter *Filter) (int, error) {
+	return d.DeleteWhereContext(context.Background(), obj, filter)
+}
+
+// DeleteWhereContext is like DeleteWhere but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) DeleteWhereContext(ctx context.Context, obj Storable, filter *Filter) (_ int, err error) {
+	op := d.newOperation(ctx, "delete where", obj.BoltBucket(), nil)
+	defer op.finish(&err)
+
+	_, decode := obj.(HasBeforeDelete)
+	return d.mutateWhere(op, obj, filter, decode, func(bucket *bbolt.Bucket, k []byte, record Storable) error {
+		if record == nil {
+			// the record is not decoded since there is no hook to call, obj is enough to tell how to delete it
+			record = obj
+		}
+		return d.delete(op, boltTx{bucket.Tx()}, bucket, k, record)
+	})
+}
+
+// UpdateWhere calls fn with every decoded record in the bucket of obj which matches the filter and stores it back,
+// returns the count of updated records. fn should not change the key of the record.
+// The records are selected and updated in one write transaction, or in batches if the filter has a batch size.
+func (d *DB) UpdateWhere(obj Storable, filter *Filter, fn func(obj Storable) error) (int, error) {
+	return d.UpdateWhereContext(context.Background(), obj, filter, fn)
+}
+
+// UpdateWhereContext is like UpdateWhere but with a context, it aborts with ctx.Err() once ctx is done.
+func (d *DB) UpdateWhereContext(ctx context.Context, obj Storable, filter *Filter, fn func(obj Storable) error) (_ int, err error) {
+	op := d.newOperation(ctx, "update where", obj.BoltBucket(), nil)
+	defer op.finish(&err)
+
+	return d.mutateWhere(op, obj, filter, true, func(bucket *bbolt.Bucket, k []byte, obj Storable) error {
+		if err := fn(obj); err != nil {
+			return &OpError{
+				Bucket: obj.BoltBucket(),
+				Key:    bytes.Clone(k),
+				Err:    err,
+			}
+		}
+		if !bytes.Equal(k, obj.BoltKey()) {
+			return &OpError{
+				Bucket: obj.BoltBucket(),
+				Key:    bytes.Clone(k),
+				Err:    fmt.Errorf("key of %T changed to %q", obj, obj.BoltKey()),
+			}
+		}
+		return d.put(op, boltTx{bucket.Tx()}, bucket, obj)
+	})
+}
+
+func (d *DB) mutateWhere(op *operation, obj Storable, filter *Filter, decode bool,
+	fn func(bucket *bbolt.Bucket, k []byte, obj Storable) error) (int, error) {
+	if filter.getLess() != nil {
+		return 0, fmt.Errorf("sorting is not supported")
+	}
+	if err := d.checkAuditBucket(obj.BoltBucket()); err != nil {
+		return 0, err
+	}
+
+	type record struct {
+		key []byte
+		obj Storable
+	}
+
+	itemType := reflect.TypeOf(obj).Elem()
+	newObj := func() Storable {
+		return reflect.New(itemType).Interface().(Storable)
+	}
+
+	limit := filter.getLimit()
+	batchSize := filter.getBatchSize()
+	total := 0
+	var last []byte
+	for {
+		size := batchSize
+		if limit > 0 && (size <= 0 || limit-total < size) {
+			size = limit - total
+		}
+
+		var records []record
+		if err := d.update(op.ctx, func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket(obj.BoltBucket())
+			if bucket == nil {
+				return nil
+			}
+
+			if err := d.iterate(op, bucket.Cursor(), filter.resume(last, size), newObj, decode, func(k, v []byte, obj Storable) (bool, error) {
+				key := make([]byte, len(k))
+				copy(key, k)
+				records = append(records, record{
+					key: key,
+					obj: obj,
+				})
+				return false, nil
+			}); err != nil {
+				return err
+			}
+
+			for _, v := range records {
+				if err := op.ctx.Err(); err != nil {
+					return err
+				}
+				if err := fn(bucket, v.key, v.obj); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return total, err
+		}
+
+		total += len(records)
+		if batchSize <= 0 || len(records) < size || (limit > 0 && total >= limit) {
+			return total, nil
+		}
+		last = records[len(records)-1].key
+	}
+}
@@ -0,0 +1,153 @@
+package boltutil
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testWhereDB(t *testing.T) *DB {
+	db := testDB(t, true)
+	var persons []Storable
+	for i := 0; i < 10; i++ {
+		persons = append(persons, &Person{
+			Id:   fmt.Sprintf("user/%02d", i),
+			Name: fmt.Sprintf("user %d", i),
+			Age:  i * 10,
+		})
+	}
+	persons = append(persons, &Person{Id: "admin", Name: "admin", Age: 99})
+	require.NoError(t, db.MPut(persons...))
+	return db
+}
+
+func TestDB_DeleteWhere(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    *Filter
+		want      int
+		wantCount int
+	}{
+		{
+			name:      "all",
+			filter:    nil,
+			want:      11,
+			wantCount: 0,
+		},
+		{
+			name:      "prefix and condition",
+			filter:    NewFilter().SetPrefix([]byte("user/")).AddStorableCondition(ageBelow(50)),
+			want:      5,
+			wantCount: 6,
+		},
+		{
+			name:      "batch",
+			filter:    NewFilter().SetPrefix([]byte("user/")).AddStorableCondition(ageBelow(50)).SetBatchSize(2),
+			want:      5,
+			wantCount: 6,
+		},
+		{
+			name:      "batch with limit",
+			filter:    NewFilter().SetPrefix([]byte("user/")).SetBatchSize(2).SetLimit(5),
+			want:      5,
+			wantCount: 6,
+		},
+		{
+			name:      "limit",
+			filter:    NewFilter().SetLimit(3),
+			want:      3,
+			wantCount: 8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testWhereDB(t)
+			defer db.Close()
+
+			got, err := db.DeleteWhere(&Person{}, tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			count, err := db.Count(&Person{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCount, count)
+		})
+	}
+
+	t.Run("bucket not exists", func(t *testing.T) {
+		db := testWhereDB(t)
+		defer db.Close()
+		got, err := db.DeleteWhere(&Wind{}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 0, got)
+	})
+
+	t.Run("sorted", func(t *testing.T) {
+		db := testWhereDB(t)
+		defer db.Close()
+		_, err := db.DeleteWhere(&Person{}, NewFilter().SortBy("Age"))
+		assert.Error(t, err)
+	})
+}
+
+func TestDB_UpdateWhere(t *testing.T) {
+	t.Run("regular", func(t *testing.T) {
+		db := testWhereDB(t)
+		defer db.Close()
+
+		got, err := db.UpdateWhere(&Person{}, NewFilter().AddStorableCondition(ageBelow(50)).SetBatchSize(3), func(obj Storable) error {
+			obj.(*Person).Age += 100
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 5, got)
+
+		count, err := db.Count(&Person{}, NewFilter().AddStorableCondition(ageBelow(100)))
+		require.NoError(t, err)
+		assert.Equal(t, 6, count)
+
+		person := &Person{Id: "user/01"}
+		require.NoError(t, db.Get(person))
+		assert.Equal(t, 110, person.Age)
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		db := testWhereDB(t)
+		defer db.Close()
+
+		boom := errors.New("boom")
+		got, err := db.UpdateWhere(&Person{}, nil, func(obj Storable) error {
+			if obj.(*Person).Id == "user/05" {
+				return boom
+			}
+			obj.(*Person).Age = 1000
+			return nil
+		})
+		assert.ErrorIs(t, err, boom)
+		assert.Equal(t, 0, got)
+
+		person := &Person{Id: "user/01"}
+		require.NoError(t, db.Get(person))
+		assert.Equal(t, 10, person.Age)
+	})
+
+	t.Run("key changed", func(t *testing.T) {
+		db := testWhereDB(t)
+		defer db.Close()
+
+		_, err := db.UpdateWhere(&Person{}, nil, func(obj Storable) error {
+			obj.(*Person).Id += "x"
+			return nil
+		})
+		assert.Error(t, err)
+	})
+}
+
+func ageBelow(age int) func(obj Storable) (bool, bool) {
+	return func(obj Storable) (bool, bool) {
+		return obj.(*Person).Age >= age, false
+	}
+}